@@ -10,6 +10,14 @@ type Config struct {
 	PRIVATE_KEY      string
 	MINIO_ACCESS_KEY string
 	MINIO_SECRET_KEY string
+	AUTO_MIGRATE     bool
+	ADMIN_EMAIL      string
+	ADMIN_PASSWORD   string
+	ADMIN_PHONE      string
+	TRACE_EXPORTER   string
+	OTLP_ENDPOINT    string
+	OTLP_INSECURE    bool
+	SERVICE_NAME     string
 }
 
 func ReadInConfig() Config {
@@ -19,10 +27,23 @@ func ReadInConfig() Config {
 		PRIVATE_KEY:      viper.GetString("PRIVATE_KEY_PATH"),
 		MINIO_ACCESS_KEY: viper.GetString("MINIO_ACCESS_KEY"),
 		MINIO_SECRET_KEY: viper.GetString("MINIO_SECRET_KEY"),
+		AUTO_MIGRATE:     viper.GetBool("AUTO_MIGRATE"),
+		ADMIN_EMAIL:      viper.GetString("ADMIN_EMAIL"),
+		ADMIN_PASSWORD:   viper.GetString("ADMIN_PASSWORD"),
+		ADMIN_PHONE:      viper.GetString("ADMIN_PHONE"),
+		TRACE_EXPORTER:   viper.GetString("TRACE_EXPORTER"),
+		OTLP_ENDPOINT:    viper.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTLP_INSECURE:    viper.GetBool("OTEL_EXPORTER_OTLP_INSECURE"),
+		SERVICE_NAME:     viper.GetString("OTEL_SERVICE_NAME"),
 	}
 }
 
 func LoadConfig() error {
+	viper.SetDefault("AUTO_MIGRATE", true)
+	viper.SetDefault("TRACE_EXPORTER", "none")
+	viper.SetDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317")
+	viper.SetDefault("OTEL_EXPORTER_OTLP_INSECURE", true)
+	viper.SetDefault("OTEL_SERVICE_NAME", "work01")
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
 	if err := viper.ReadInConfig(); err != nil {
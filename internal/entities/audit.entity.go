@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditRoleExpired           = "role.expired"
+	AuditRoleAssignmentExpired = "role_assignment.expired"
+	AuditSessionRevoked        = "session.revoked"
+	AuditRoleReassigned        = "role.reassigned"
+)
+
+// AuditLog records a change made to an entity. ActorId is empty for changes
+// made by background jobs, and Details holds a JSON document describing the
+// change.
+type AuditLog struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;"`
+	Action     string     `json:"action" gorm:"type:varchar(100);not null;index"`
+	EntityType string     `json:"entityType" gorm:"type:varchar(50);not null;index:idx_audit_logs_entity"`
+	EntityId   uuid.UUID  `json:"entityId" gorm:"type:uuid;index:idx_audit_logs_entity"`
+	ActorId    *uuid.UUID `json:"actorId" gorm:"type:uuid"`
+	Details    string     `json:"details" gorm:"type:text"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"index"`
+}
+
+var AuditLogSortFields = map[string]string{
+	"createdAt": "created_at",
+	"action":    "action",
+}
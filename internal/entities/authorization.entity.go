@@ -34,3 +34,8 @@ type ResLogin struct {
 	RefreshToken string      `json:"refreshToken"`
 	User         interface{} `json:"user"`
 }
+
+var AuthorizationSortFields = map[string]string{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
@@ -2,6 +2,7 @@ package entities
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Feature struct {
@@ -9,13 +10,60 @@ type Feature struct {
 	Name         string     `json:"name" gorm:"type:varchar"`
 	ParentMenuId *uuid.UUID `json:"parentMenuId"`
 	// ParentMenu   *Feature   `json:"parentMenu"`
-	MenuIcon   string `json:"menuIcon" gorm:"type:varchar"`
-	MenuNameTh string `json:"menuNameTh" gorm:"type:varchar"`
-	MenuNameEn string `json:"menuNameEn" gorm:"type:varchar"`
-	MenuSlug   string `json:"menuSlug" gorm:"type:varchar"`
-	MenuSeqNo  string `json:"menuSeqNo" gorm:"type:varchar"`
-	IsActive   *bool  `json:"isActive" gorm:"default:true"`
-	Roles      []Role `json:"-" gorm:"many2many:role_features;"`
+	MenuIcon   string          `json:"menuIcon" gorm:"type:varchar"`
+	MenuNameTh string          `json:"menuNameTh" gorm:"type:varchar"`
+	MenuNameEn string          `json:"menuNameEn" gorm:"type:varchar"`
+	MenuSlug   string          `json:"menuSlug" gorm:"type:varchar;uniqueIndex:idx_features_menu_slug,where:deleted_at IS NULL"`
+	MenuSeqNo  int32           `json:"menuSeqNo" gorm:"type:integer;not null;default:0"`
+	IsActive   *bool           `json:"isActive" gorm:"default:true"`
+	Actions    []string        `json:"actions" gorm:"-"`
+	Roles      []Role          `json:"-" gorm:"many2many:role_features;"`
+	DeletedAt  *gorm.DeletedAt `json:"-"`
+	DeletedBy  *uuid.UUID      `json:"-" gorm:"type:uuid;index;"`
+}
+
+const (
+	PermissionView   = "view"
+	PermissionAdd    = "add"
+	PermissionEdit   = "edit"
+	PermissionDelete = "delete"
+)
+
+// StandardActions are the actions every feature had before features could
+// declare their own; each is mirrored by one of the Is* flags.
+var StandardActions = []string{PermissionView, PermissionAdd, PermissionEdit, PermissionDelete}
+
+// FeatureAction declares that Action can be granted on a feature.
+type FeatureAction struct {
+	FeatureId uuid.UUID `json:"featureId" gorm:"type:uuid;primaryKey;"`
+	Action    string    `json:"action" gorm:"type:varchar(50);primaryKey;"`
+}
+
+// ActionsFromFlags lists the standard actions granted by the legacy flags.
+func ActionsFromFlags(isView, isAdd, isEdit, isDelete *bool) []string {
+	actions := []string{}
+	for i, flag := range []*bool{isView, isAdd, isEdit, isDelete} {
+		if flag != nil && *flag {
+			actions = append(actions, StandardActions[i])
+		}
+	}
+
+	return actions
+}
+
+func HasAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+func actionFlag(actions []string, action string) *bool {
+	granted := HasAction(actions, action)
+	return &granted
 }
 
 type FeatureDTO struct {
@@ -35,12 +83,86 @@ type FeatureDTODetails struct {
 	MenuNameTh   string     `json:"menuNameTh"`
 	MenuNameEn   string     `json:"menuNameEn"`
 	MenuSlug     string     `json:"menuSlug"`
-	MenuSeqNo    string     `json:"menuSeqNo"`
+	MenuSeqNo    int32      `json:"menuSeqNo"`
 	IsActive     *bool      `json:"isActive"`
 	IsAdd        *bool      `json:"isAdd"`
 	IsView       *bool      `json:"isView"`
 	IsEdit       *bool      `json:"isEdit"`
 	IsDelete     *bool      `json:"isDelete"`
+	Actions      []string   `json:"actions"`
+	Overridden   []string   `json:"overridden,omitempty"`
+}
+
+func (f FeatureDTODetails) Allows(action string) bool {
+	if f.Actions == nil {
+		return HasAction(ActionsFromFlags(f.IsView, f.IsAdd, f.IsEdit, f.IsDelete), action)
+	}
+
+	return HasAction(f.Actions, action)
+}
+
+// Reasons given with a permission decision.
+const (
+	ReasonRoleGrant       = "role_grant"
+	ReasonOverrideGrant   = "override_grant"
+	ReasonOverrideDeny    = "override_deny"
+	ReasonNotGranted      = "not_granted"
+	ReasonFeatureInactive = "feature_inactive"
+	ReasonNoFeature       = "feature_not_granted"
+)
+
+// PermissionCheck asks whether an action is allowed on the feature with the
+// given menu slug.
+type PermissionCheck struct {
+	Feature string `json:"feature"`
+	Action  string `json:"action"`
+}
+
+type ReqPermissionCheck struct {
+	UserId *uuid.UUID        `json:"userId"`
+	Checks []PermissionCheck `json:"checks"`
+}
+
+type PermissionDecision struct {
+	Feature string `json:"feature"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// DecidePermission evaluates action against the user's permission on a
+// feature, nil when neither the roles nor an override include the feature.
+// It is the one evaluator behind the permission middleware and the check API.
+func DecidePermission(permission *FeatureDTODetails, action string) (bool, string) {
+	if permission == nil {
+		return false, ReasonNoFeature
+	}
+
+	if permission.IsActive == nil || !*permission.IsActive {
+		return false, ReasonFeatureInactive
+	}
+
+	allowed := permission.Allows(action)
+	if HasAction(permission.Overridden, action) {
+		if allowed {
+			return true, ReasonOverrideGrant
+		}
+		return false, ReasonOverrideDeny
+	}
+
+	if allowed {
+		return true, ReasonRoleGrant
+	}
+	return false, ReasonNotGranted
+}
+
+// SetActions stores the granted actions and keeps the legacy flags in step.
+func (f *FeatureDTODetails) SetActions(actions []string) {
+	f.Actions = actions
+	f.IsView = actionFlag(actions, PermissionView)
+	f.IsAdd = actionFlag(actions, PermissionAdd)
+	f.IsEdit = actionFlag(actions, PermissionEdit)
+	f.IsDelete = actionFlag(actions, PermissionDelete)
 }
 
 type RefFeatureDTO struct {
@@ -52,6 +174,39 @@ type RefFeatureDTO struct {
 	IsDelete     *bool     `json:"isDelete"`
 }
 
+type MenuItem struct {
+	FeatureId uuid.UUID  `json:"featureId"`
+	Name      string     `json:"name"`
+	MenuSlug  string     `json:"menuSlug"`
+	MenuIcon  *string    `json:"menuIcon"`
+	MenuSeqNo int32      `json:"menuSeqNo"`
+	Children  []MenuItem `json:"children"`
+}
+
+type ReqFeatureMove struct {
+	ParentMenuId *uuid.UUID `json:"parentMenuId"`
+	Position     int        `json:"position"`
+}
+
+type ReqFeatureReorder struct {
+	ParentMenuId *uuid.UUID  `json:"parentMenuId"`
+	FeatureIds   []uuid.UUID `json:"featureIds"`
+}
+
+type FeatureRef struct {
+	FeatureId uuid.UUID `json:"featureId"`
+	Name      string    `json:"name"`
+}
+
+type ResFeatureDeletePreview struct {
+	FeatureId   uuid.UUID            `json:"featureId"`
+	FeatureName string               `json:"featureName"`
+	CanDelete   bool                 `json:"canDelete"`
+	Descendants []FeatureRef         `json:"descendants"`
+	Roles       []ResAllRoleDropDown `json:"roles"`
+	NumberUser  int64                `json:"numberUser"`
+}
+
 type ResMenuIcon struct {
 	MenuIcon string `json:"menuIcon"`
 }
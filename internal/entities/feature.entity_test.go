@@ -0,0 +1,36 @@
+package entities
+
+import "testing"
+
+func TestDecidePermission(t *testing.T) {
+	active := true
+	inactive := false
+
+	tests := []struct {
+		name        string
+		permission  *FeatureDTODetails
+		action      string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{"feature not granted", nil, PermissionView, false, ReasonNoFeature},
+		{"inactive feature", &FeatureDTODetails{IsActive: &inactive, Actions: []string{PermissionView}}, PermissionView, false, ReasonFeatureInactive},
+		{"active unset", &FeatureDTODetails{Actions: []string{PermissionView}}, PermissionView, false, ReasonFeatureInactive},
+		{"role grant", &FeatureDTODetails{IsActive: &active, Actions: []string{PermissionView}}, PermissionView, true, ReasonRoleGrant},
+		{"not granted", &FeatureDTODetails{IsActive: &active, Actions: []string{PermissionView}}, PermissionEdit, false, ReasonNotGranted},
+		{"custom action", &FeatureDTODetails{IsActive: &active, Actions: []string{"export"}}, "export", true, ReasonRoleGrant},
+		{"legacy flags", &FeatureDTODetails{IsActive: &active, IsEdit: &active}, PermissionEdit, true, ReasonRoleGrant},
+		{"override grant", &FeatureDTODetails{IsActive: &active, Actions: []string{PermissionView}, Overridden: []string{PermissionView}}, PermissionView, true, ReasonOverrideGrant},
+		{"override deny", &FeatureDTODetails{IsActive: &active, Actions: []string{}, Overridden: []string{PermissionView}}, PermissionView, false, ReasonOverrideDeny},
+		{"override of another action", &FeatureDTODetails{IsActive: &active, Actions: []string{PermissionView}, Overridden: []string{PermissionEdit}}, PermissionView, true, ReasonRoleGrant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason := DecidePermission(tt.permission, tt.action)
+			if allowed != tt.wantAllowed || reason != tt.wantReason {
+				t.Errorf("DecidePermission() = (%v, %s), want (%v, %s)", allowed, reason, tt.wantAllowed, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package entities
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrgUnit is a node of the organization tree, e.g. a region or a branch.
+// Users belong to at most one unit.
+type OrgUnit struct {
+	ID        uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;"`
+	Name      string          `json:"name" gorm:"type:varchar;not null"`
+	ParentId  *uuid.UUID      `json:"parentId" gorm:"type:uuid;index"`
+	CreatedAt time.Time       `json:"createdAt"`
+	CreatedBy uuid.UUID       `json:"createdBy" gorm:"type:uuid"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	UpdatedBy uuid.UUID       `json:"updatedBy" gorm:"type:uuid"`
+	DeletedAt *gorm.DeletedAt `json:"-"`
+	DeletedBy *uuid.UUID      `json:"-" gorm:"type:uuid;index;"`
+}
+
+type ReqOrgUnit struct {
+	Name     string     `json:"name"`
+	ParentId *uuid.UUID `json:"parentId"`
+}
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+
+	ResourceUser               = "user"
+	ResourceRole               = "role"
+	ResourcePolicy             = "policy"
+	ResourceOrgUnit            = "org_unit"
+	ResourcePermissionTemplate = "permission_template"
+)
+
+// Actions the policy engine is asked about.
+const (
+	ActionUserUpdate         = "update"
+	ActionUserChangePassword = "change_password"
+	ActionUserDelete         = "delete"
+	ActionUserOverride       = "override_permissions"
+	ActionUserCheck          = "check_permissions"
+	ActionRoleAssign         = "assign"
+	ActionRoleCreate         = "create"
+	ActionRoleManage         = "manage"
+	ActionManage             = "manage"
+)
+
+// PolicyActions lists the actions a policy can be written for, by resource.
+var PolicyActions = map[string][]string{
+	ResourceUser:               {ActionUserUpdate, ActionUserChangePassword, ActionUserDelete, ActionUserOverride, ActionUserCheck},
+	ResourceRole:               {ActionRoleAssign, ActionRoleCreate, ActionRoleManage},
+	ResourcePolicy:             {ActionManage},
+	ResourceOrgUnit:            {ActionManage},
+	ResourcePermissionTemplate: {ActionManage},
+}
+
+// Condition operators. Ordering operators compare numbers, in and contains
+// compare against a list.
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpIn       = "in"
+	OpContains = "contains"
+)
+
+// PolicyCondition compares an attribute of the subject (the acting user) or
+// the resource with Value. A Value starting with "subject." or "resource." is
+// read from the attributes as well; otherwise it is a literal, comma separated
+// for "in".
+type PolicyCondition struct {
+	Attribute string `json:"attribute"`
+	Operator  string `json:"operator"`
+	Value     string `json:"value"`
+}
+
+// AccessPolicy allows or denies Action on Resource when all of its
+// conditions hold. A matching deny wins over any allow; with no matching
+// allow the action is denied. Message is returned when the policy denies.
+type AccessPolicy struct {
+	ID          uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey;"`
+	Name        string            `json:"name" gorm:"type:varchar;not null;uniqueIndex"`
+	Description string            `json:"description" gorm:"type:varchar"`
+	Resource    string            `json:"resource" gorm:"type:varchar(50);not null;index:idx_access_policies_target"`
+	Action      string            `json:"action" gorm:"type:varchar(50);not null;index:idx_access_policies_target"`
+	Effect      string            `json:"effect" gorm:"type:varchar(10);not null"`
+	Conditions  []PolicyCondition `json:"conditions" gorm:"type:text;serializer:json"`
+	Message     string            `json:"message" gorm:"type:varchar"`
+	IsActive    *bool             `json:"isActive" gorm:"default:true"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	CreatedBy   uuid.UUID         `json:"createdBy" gorm:"type:uuid"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	UpdatedBy   uuid.UUID         `json:"updatedBy" gorm:"type:uuid"`
+	DeletedAt   *gorm.DeletedAt   `json:"-"`
+	DeletedBy   *uuid.UUID        `json:"-" gorm:"type:uuid;index;"`
+}
+
+type ReqAccessPolicy struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Resource    string            `json:"resource"`
+	Action      string            `json:"action"`
+	Effect      string            `json:"effect"`
+	Conditions  []PolicyCondition `json:"conditions"`
+	Message     string            `json:"message"`
+	IsActive    *bool             `json:"isActive"`
+}
+
+// DefaultAccessPolicies are always evaluated next to the stored policies and
+// carry the role level hierarchy: a user manages users and roles below (or,
+// for updates and role assignment, at) their own level. Policies, org units
+// and permission templates are managed by holders of the super administrator
+// system role only, whatever its level.
+var DefaultAccessPolicies = []AccessPolicy{
+	{Name: "user-update-self", Resource: ResourceUser, Action: ActionUserUpdate, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.id", OpEq, "resource.id"}}},
+	{Name: "user-update-level", Resource: ResourceUser, Action: ActionUserUpdate, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGte, "resource.level"}}},
+	{Name: "user-change-password-self", Resource: ResourceUser, Action: ActionUserChangePassword, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.id", OpEq, "resource.id"}}},
+	{Name: "user-change-password-level", Resource: ResourceUser, Action: ActionUserChangePassword, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGte, "resource.level"}}},
+	{Name: "user-delete-self", Resource: ResourceUser, Action: ActionUserDelete, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.id", OpEq, "resource.id"}}},
+	{Name: "user-delete-active", Resource: ResourceUser, Action: ActionUserDelete, Effect: PolicyDeny,
+		Conditions: []PolicyCondition{{"subject.id", OpNe, "resource.id"}, {"resource.status", OpEq, "active"}},
+		Message:    "can not delete user that is active"},
+	{Name: "user-delete-level", Resource: ResourceUser, Action: ActionUserDelete, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGt, "resource.level"}}},
+	{Name: "user-override-self", Resource: ResourceUser, Action: ActionUserOverride, Effect: PolicyDeny,
+		Conditions: []PolicyCondition{{"subject.id", OpEq, "resource.id"}},
+		Message:    "you can not override your own permissions"},
+	{Name: "user-override-level", Resource: ResourceUser, Action: ActionUserOverride, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGt, "resource.level"}}},
+	{Name: "user-check-permissions-self", Resource: ResourceUser, Action: ActionUserCheck, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.id", OpEq, "resource.id"}}},
+	{Name: "user-check-permissions-level", Resource: ResourceUser, Action: ActionUserCheck, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGte, "resource.level"}}},
+	{Name: "role-assign-level", Resource: ResourceRole, Action: ActionRoleAssign, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGte, "resource.level"}}},
+	{Name: "role-create-level", Resource: ResourceRole, Action: ActionRoleCreate, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGt, "resource.level"}}},
+	{Name: "role-manage-level", Resource: ResourceRole, Action: ActionRoleManage, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGt, "resource.level"}}},
+	{Name: "policy-manage-super-administrator", Resource: ResourcePolicy, Action: ActionManage, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.systemRoles", OpContains, SystemRoleSuperAdministrator}}},
+	{Name: "org-unit-manage-super-administrator", Resource: ResourceOrgUnit, Action: ActionManage, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.systemRoles", OpContains, SystemRoleSuperAdministrator}}},
+	{Name: "permission-template-manage-super-administrator", Resource: ResourcePermissionTemplate, Action: ActionManage, Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.systemRoles", OpContains, SystemRoleSuperAdministrator}}},
+}
+
+// Attributes holds the values conditions are evaluated against, keyed by
+// "subject.<name>" and "resource.<name>". Single values are lists of one.
+type Attributes map[string][]string
+
+// UserAttributes are the attributes of a user, as subject or as resource.
+type UserAttributes struct {
+	ID          uuid.UUID
+	CreatedBy   uuid.UUID
+	Level       int32
+	RoleIds     []uuid.UUID
+	SystemRoles []string
+	OrgUnitId   *uuid.UUID
+	OrgUnitPath []uuid.UUID
+	IsActive    bool
+}
+
+// Set stores the attributes of u under prefix: id, owner, level, roleIds,
+// systemRoles (the system keys of those roles), orgUnit, orgUnits (the unit
+// and all of its ancestors) and status.
+func (a Attributes) Set(prefix string, u UserAttributes) {
+	a[prefix+".id"] = []string{u.ID.String()}
+	a[prefix+".owner"] = []string{u.CreatedBy.String()}
+	a[prefix+".level"] = []string{strconv.Itoa(int(u.Level))}
+	a[prefix+".roleIds"] = idStrings(u.RoleIds)
+	a[prefix+".systemRoles"] = u.SystemRoles
+	if u.OrgUnitId != nil {
+		a[prefix+".orgUnit"] = []string{u.OrgUnitId.String()}
+	}
+	a[prefix+".orgUnits"] = idStrings(u.OrgUnitPath)
+	if u.IsActive {
+		a[prefix+".status"] = []string{"active"}
+	} else {
+		a[prefix+".status"] = []string{"inactive"}
+	}
+}
+
+// SetRole stores the attributes of role as the resource.
+func (a Attributes) SetRole(role Role) {
+	a["resource.id"] = []string{role.ID.String()}
+	a["resource.owner"] = []string{role.CreatedBy.String()}
+	a["resource.level"] = []string{strconv.Itoa(int(role.Level))}
+}
+
+func idStrings(ids []uuid.UUID) []string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, id.String())
+	}
+	return s
+}
+
+func (a Attributes) resolve(value string) []string {
+	if strings.HasPrefix(value, "subject.") || strings.HasPrefix(value, "resource.") {
+		return a[value]
+	}
+	return []string{value}
+}
+
+// Matches reports whether the condition holds. A condition on an attribute
+// that is not set never holds.
+func (c PolicyCondition) Matches(a Attributes) bool {
+	left := a[c.Attribute]
+	if len(left) == 0 {
+		return false
+	}
+
+	var right []string
+	if c.Operator == OpIn && !strings.HasPrefix(c.Value, "subject.") && !strings.HasPrefix(c.Value, "resource.") {
+		right = strings.Split(c.Value, ",")
+	} else {
+		right = a.resolve(c.Value)
+	}
+	if len(right) == 0 {
+		return false
+	}
+
+	switch c.Operator {
+	case OpEq:
+		return left[0] == right[0]
+	case OpNe:
+		return left[0] != right[0]
+	case OpGt, OpGte, OpLt, OpLte:
+		l, err := strconv.ParseFloat(left[0], 64)
+		if err != nil {
+			return false
+		}
+		r, err := strconv.ParseFloat(right[0], 64)
+		if err != nil {
+			return false
+		}
+		switch c.Operator {
+		case OpGt:
+			return l > r
+		case OpGte:
+			return l >= r
+		case OpLt:
+			return l < r
+		default:
+			return l <= r
+		}
+	case OpIn:
+		return containsString(right, left[0])
+	case OpContains:
+		return containsString(left, right[0])
+	}
+
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if strings.TrimSpace(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p AccessPolicy) Matches(a Attributes) bool {
+	if p.IsActive != nil && !*p.IsActive {
+		return false
+	}
+
+	for _, c := range p.Conditions {
+		if !c.Matches(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyDecision is the outcome of evaluating the policies of an action.
+// Policy names the deciding policy, empty when nothing allowed the action.
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Policy  string `json:"policy,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// EvaluatePolicies applies deny-overrides to policies.
+func EvaluatePolicies(policies []AccessPolicy, a Attributes) PolicyDecision {
+	var allowedBy string
+	for _, p := range policies {
+		if !p.Matches(a) {
+			continue
+		}
+
+		if p.Effect == PolicyDeny {
+			return PolicyDecision{Allowed: false, Policy: p.Name, Message: p.Message}
+		}
+		if allowedBy == "" {
+			allowedBy = p.Name
+		}
+	}
+
+	if allowedBy == "" {
+		return PolicyDecision{Allowed: false}
+	}
+	return PolicyDecision{Allowed: true, Policy: allowedBy}
+}
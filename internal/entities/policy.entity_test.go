@@ -0,0 +1,149 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPolicyConditionMatches(t *testing.T) {
+	a := Attributes{
+		"subject.id":          {"u1"},
+		"subject.level":       {"50"},
+		"subject.roleIds":     {"r1", "r2"},
+		"subject.systemRoles": {SystemRoleSuperAdministrator},
+		"resource.id":         {"u2"},
+		"resource.level":      {"40"},
+		"resource.orgUnit":    {"o1"},
+	}
+
+	tests := []struct {
+		name      string
+		condition PolicyCondition
+		want      bool
+	}{
+		{"eq literal", PolicyCondition{"subject.id", OpEq, "u1"}, true},
+		{"eq attribute", PolicyCondition{"subject.id", OpEq, "resource.id"}, false},
+		{"ne attribute", PolicyCondition{"subject.id", OpNe, "resource.id"}, true},
+		{"gt numeric", PolicyCondition{"subject.level", OpGt, "resource.level"}, true},
+		{"gte equal", PolicyCondition{"subject.level", OpGte, "50"}, true},
+		{"lt numeric", PolicyCondition{"subject.level", OpLt, "resource.level"}, false},
+		{"lte equal", PolicyCondition{"resource.level", OpLte, "40"}, true},
+		{"numbers compare as numbers", PolicyCondition{"subject.level", OpGt, "9"}, true},
+		{"ordering on a non number", PolicyCondition{"subject.id", OpGt, "1"}, false},
+		{"in literal list", PolicyCondition{"resource.orgUnit", OpIn, "o2, o1"}, true},
+		{"in attribute list", PolicyCondition{"resource.orgUnit", OpIn, "subject.roleIds"}, false},
+		{"contains", PolicyCondition{"subject.roleIds", OpContains, "r2"}, true},
+		{"contains system role", PolicyCondition{"subject.systemRoles", OpContains, SystemRoleSuperAdministrator}, true},
+		{"missing attribute", PolicyCondition{"resource.owner", OpEq, "u1"}, false},
+		{"missing value attribute", PolicyCondition{"subject.id", OpEq, "resource.owner"}, false},
+		{"unknown operator", PolicyCondition{"subject.id", "like", "u1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.Matches(a); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	inactive := false
+	a := Attributes{"subject.level": {"50"}, "resource.level": {"40"}}
+
+	allowLevel := AccessPolicy{Name: "allow-level", Effect: PolicyAllow,
+		Conditions: []PolicyCondition{{"subject.level", OpGt, "resource.level"}}}
+	allowAll := AccessPolicy{Name: "allow-all", Effect: PolicyAllow}
+	denyLevel := AccessPolicy{Name: "deny-level", Effect: PolicyDeny, Message: "denied",
+		Conditions: []PolicyCondition{{"resource.level", OpGte, "40"}}}
+	denyOther := AccessPolicy{Name: "deny-other", Effect: PolicyDeny,
+		Conditions: []PolicyCondition{{"resource.level", OpGt, "40"}}}
+
+	tests := []struct {
+		name     string
+		policies []AccessPolicy
+		want     PolicyDecision
+	}{
+		{"no policies", nil, PolicyDecision{Allowed: false}},
+		{"first allow decides", []AccessPolicy{allowLevel, allowAll}, PolicyDecision{Allowed: true, Policy: "allow-level"}},
+		{"deny overrides an earlier allow", []AccessPolicy{allowLevel, denyLevel}, PolicyDecision{Allowed: false, Policy: "deny-level", Message: "denied"}},
+		{"deny overrides a later allow", []AccessPolicy{denyLevel, allowAll}, PolicyDecision{Allowed: false, Policy: "deny-level", Message: "denied"}},
+		{"deny that does not match", []AccessPolicy{denyOther, allowLevel}, PolicyDecision{Allowed: true, Policy: "allow-level"}},
+		{"inactive allow", []AccessPolicy{{Name: "off", Effect: PolicyAllow, IsActive: &inactive}}, PolicyDecision{Allowed: false}},
+		{"inactive deny", []AccessPolicy{{Name: "off", Effect: PolicyDeny, IsActive: &inactive}, allowAll}, PolicyDecision{Allowed: true, Policy: "allow-all"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EvaluatePolicies(tt.policies, a); got != tt.want {
+				t.Errorf("EvaluatePolicies() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultAccessPolicies(t *testing.T) {
+	self := uuid.New()
+	other := uuid.New()
+
+	subject := func(level int32, systemRoles ...string) UserAttributes {
+		return UserAttributes{ID: self, Level: level, SystemRoles: systemRoles, IsActive: true}
+	}
+	resource := func(id uuid.UUID, level int32, active bool) UserAttributes {
+		return UserAttributes{ID: id, Level: level, IsActive: active}
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		action   string
+		subject  UserAttributes
+		target   *UserAttributes
+		want     bool
+	}{
+		{"update self", ResourceUser, ActionUserUpdate, subject(1), ptr(resource(self, 1, true)), true},
+		{"update same level", ResourceUser, ActionUserUpdate, subject(10), ptr(resource(other, 10, true)), true},
+		{"update higher level", ResourceUser, ActionUserUpdate, subject(10), ptr(resource(other, 20, true)), false},
+		{"delete self", ResourceUser, ActionUserDelete, subject(1), ptr(resource(self, 1, true)), true},
+		{"delete active user below", ResourceUser, ActionUserDelete, subject(20), ptr(resource(other, 10, true)), false},
+		{"delete inactive user below", ResourceUser, ActionUserDelete, subject(20), ptr(resource(other, 10, false)), true},
+		{"delete inactive user at same level", ResourceUser, ActionUserDelete, subject(10), ptr(resource(other, 10, false)), false},
+		{"override self", ResourceUser, ActionUserOverride, subject(100), ptr(resource(self, 100, true)), false},
+		{"override user below", ResourceUser, ActionUserOverride, subject(20), ptr(resource(other, 10, true)), true},
+		{"check self", ResourceUser, ActionUserCheck, subject(1), ptr(resource(self, 1, true)), true},
+		{"check user above", ResourceUser, ActionUserCheck, subject(10), ptr(resource(other, 20, true)), false},
+		{"manage policies as super administrator", ResourcePolicy, ActionManage, subject(1, SystemRoleSuperAdministrator), nil, true},
+		{"manage policies at a high level", ResourcePolicy, ActionManage, subject(1000), nil, false},
+		{"manage org units as super administrator", ResourceOrgUnit, ActionManage, subject(1, SystemRoleSuperAdministrator), nil, true},
+		{"manage org units with another system role", ResourceOrgUnit, ActionManage, subject(1000, "auditor"), nil, false},
+		{"manage permission templates as super administrator", ResourcePermissionTemplate, ActionManage, subject(1, SystemRoleSuperAdministrator), nil, true},
+		{"manage permission templates at a high level", ResourcePermissionTemplate, ActionManage, subject(1000), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Attributes{}
+			a.Set("subject", tt.subject)
+			if tt.target != nil {
+				a.Set("resource", *tt.target)
+			}
+
+			var policies []AccessPolicy
+			for _, p := range DefaultAccessPolicies {
+				if p.Resource == tt.resource && p.Action == tt.action {
+					policies = append(policies, p)
+				}
+			}
+
+			if got := EvaluatePolicies(policies, a); got.Allowed != tt.want {
+				t.Errorf("EvaluatePolicies() = %+v, want allowed %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
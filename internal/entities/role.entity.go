@@ -11,6 +11,8 @@ type Role struct {
 	ID        uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;"`
 	Name      string          `json:"name" gorm:"not null;"`
 	Level     int32           `json:"level" gorm:"not null;default:0"`
+	Version   int32           `json:"version" gorm:"not null;default:1"`
+	SystemKey *string         `json:"systemKey,omitempty" gorm:"type:varchar(50);uniqueIndex"`
 	CreatedAt time.Time       `json:"createdAt"`
 	CreatedBy uuid.UUID       `json:"createdBy,omitempty" gorm:"type:uuid"`
 	UpdatedAt time.Time       `json:"updatedAt"`
@@ -21,6 +23,20 @@ type Role struct {
 	Features  []Feature       `json:"features" gorm:"many2many:role_features;"`
 }
 
+// SystemRoleSuperAdministrator is the key of the role that holds every
+// permission. At least one active user always holds it.
+const SystemRoleSuperAdministrator = "super_administrator"
+
+// IsSystem reports whether role is a system role. System roles can not be
+// deleted or renamed, and their level and permissions can not be reduced.
+func (role Role) IsSystem() bool {
+	return role.SystemKey != nil
+}
+
+func (role Role) IsSuperAdministrator() bool {
+	return role.SystemKey != nil && *role.SystemKey == SystemRoleSuperAdministrator
+}
+
 type RoleFeature struct {
 	ID     uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;"`
 	RoleId uuid.UUID `json:"roleId" gorm:"type:uuid;primaryKey;"`
@@ -31,6 +47,27 @@ type RoleFeature struct {
 	IsView    *bool     `json:"isView" gorm:"default:false;"`
 	IsEdit    *bool     `json:"isEdit" gorm:"default:false;"`
 	IsDelete  *bool     `json:"isDelete" gorm:"default:false;"`
+	Actions   []string  `json:"actions,omitempty" gorm:"-"`
+}
+
+// RoleFeatureAction grants Action on the feature of a RoleFeature row.
+type RoleFeatureAction struct {
+	RoleFeatureId uuid.UUID `json:"roleFeatureId" gorm:"type:uuid;primaryKey;"`
+	Action        string    `json:"action" gorm:"type:varchar(50);primaryKey;"`
+}
+
+// NormalizeActions makes Actions the source of the grant: clients that only
+// send the legacy flags get the matching standard actions, and the flags are
+// then rewritten from Actions so both views agree.
+func (rf *RoleFeature) NormalizeActions() {
+	if rf.Actions == nil {
+		rf.Actions = ActionsFromFlags(rf.IsView, rf.IsAdd, rf.IsEdit, rf.IsDelete)
+	}
+
+	rf.IsView = actionFlag(rf.Actions, PermissionView)
+	rf.IsAdd = actionFlag(rf.Actions, PermissionAdd)
+	rf.IsEdit = actionFlag(rf.Actions, PermissionEdit)
+	rf.IsDelete = actionFlag(rf.Actions, PermissionDelete)
 }
 
 type ResAllRoleDropDown struct {
@@ -42,6 +79,7 @@ type ResAllRoleDetails struct {
 	RoleID     uuid.UUID `json:"roleId"`
 	RoleName   string    `json:"roleName"`
 	RoleLevel  int32     `json:"roleLevel"`
+	IsSystem   bool      `json:"isSystem"`
 	NumberUser int32     `json:"numberUser"`
 }
 
@@ -51,6 +89,43 @@ type ReqRoleCreate struct {
 	Features []FeatureInRole `json:"features"`
 }
 
+type ReqRoleClone struct {
+	Name  string `json:"name"`
+	Level int32  `json:"level"`
+}
+
+// PermissionTemplate is a named set of the four flags that can be stamped on
+// many features of a role at once.
+type PermissionTemplate struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;"`
+	Name        string    `json:"name" gorm:"type:varchar;not null;uniqueIndex"`
+	Description string    `json:"description" gorm:"type:varchar"`
+	IsAdd       *bool     `json:"isAdd" gorm:"default:false;"`
+	IsView      *bool     `json:"isView" gorm:"default:false;"`
+	IsEdit      *bool     `json:"isEdit" gorm:"default:false;"`
+	IsDelete    *bool     `json:"isDelete" gorm:"default:false;"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CreatedBy   uuid.UUID `json:"createdBy,omitempty" gorm:"type:uuid"`
+}
+
+type ReqApplyTemplate struct {
+	Template   string      `json:"template"`
+	FeatureIds []uuid.UUID `json:"featureIds"`
+}
+
+func templateFlag(b bool) *bool {
+	return &b
+}
+
+// DefaultPermissionTemplates are always available; a stored template with the
+// same name takes their place.
+var DefaultPermissionTemplates = []PermissionTemplate{
+	{Name: "no-access", Description: "no permission", IsAdd: templateFlag(false), IsView: templateFlag(false), IsEdit: templateFlag(false), IsDelete: templateFlag(false)},
+	{Name: "read-only", Description: "view only", IsAdd: templateFlag(false), IsView: templateFlag(true), IsEdit: templateFlag(false), IsDelete: templateFlag(false)},
+	{Name: "editor", Description: "view, add and edit", IsAdd: templateFlag(true), IsView: templateFlag(true), IsEdit: templateFlag(true), IsDelete: templateFlag(false)},
+	{Name: "full-access", Description: "every permission", IsAdd: templateFlag(true), IsView: templateFlag(true), IsEdit: templateFlag(true), IsDelete: templateFlag(true)},
+}
+
 type FeatureInRole struct {
 	FeatureId   uuid.UUID `json:"featureId"`
 	FeatureName string    `json:"featureName"`
@@ -58,6 +133,7 @@ type FeatureInRole struct {
 	IsView      *bool     `json:"isView"`
 	IsEdit      *bool     `json:"isEdit"`
 	IsDelete    *bool     `json:"isDelete"`
+	Actions     []string  `json:"actions" gorm:"-"`
 }
 
 type ReqRoleUpdate struct {
@@ -73,6 +149,7 @@ type FeatureInRoleUpdate struct {
 	IsView      *bool     `json:"isView"`
 	IsEdit      *bool     `json:"isEdit"`
 	IsDelete    *bool     `json:"isDelete"`
+	Actions     []string  `json:"actions"`
 }
 
 type ResRoleLevel struct {
@@ -83,5 +160,71 @@ type ResRoleDetails struct {
 	RoleID    uuid.UUID       `json:"roleId"`
 	RoleName  string          `json:"roleName"`
 	RoleLevel int32           `json:"roleLevel"`
+	IsSystem  bool            `json:"isSystem"`
 	Features  []FeatureInRole `json:"features"`
 }
+
+// FeatureChange lists the actions of one feature that a role change grants
+// and revokes.
+type FeatureChange struct {
+	FeatureId   uuid.UUID `json:"featureId"`
+	FeatureName string    `json:"featureName"`
+	Granted     []string  `json:"granted"`
+	Revoked     []string  `json:"revoked"`
+}
+
+// AffectedUser is a holder of a role with the actions they would really gain
+// or lose, after their other roles and overrides.
+type AffectedUser struct {
+	UserId    uuid.UUID       `json:"userId"`
+	FirstName string          `json:"firstName"`
+	LastName  string          `json:"lastName"`
+	Email     string          `json:"email"`
+	Changes   []FeatureChange `json:"changes"`
+}
+
+// RoleReassignment records the users moved off a deleted role.
+type RoleReassignment struct {
+	ToRoleId uuid.UUID   `json:"toRoleId"`
+	UserIds  []uuid.UUID `json:"userIds"`
+}
+
+// ResRoleReassignment is the outcome of deleting a role whose users were
+// moved to another one. The role is deleted even when some of the moved users
+// could not be signed out; they are listed in SessionRevocationFailures.
+type ResRoleReassignment struct {
+	ToRoleId                  uuid.UUID                  `json:"toRoleId"`
+	UserIds                   []uuid.UUID                `json:"userIds"`
+	SessionRevocationFailures []SessionRevocationFailure `json:"sessionRevocationFailures"`
+}
+
+// SessionRevocationFailure is a user whose sessions are still valid.
+type SessionRevocationFailure struct {
+	UserId uuid.UUID `json:"userId"`
+	Error  string    `json:"error"`
+}
+
+// ResRoleChangePreview is the outcome of a role update dry run. Nothing is
+// saved; CanSave is false when the update would be rejected.
+type ResRoleChangePreview struct {
+	RoleID            uuid.UUID       `json:"roleId"`
+	RoleName          string          `json:"roleName"`
+	RoleLevel         int32           `json:"roleLevel"`
+	Changes           []FeatureChange `json:"changes"`
+	AffectedUserCount int             `json:"affectedUserCount"`
+	AffectedUsers     []AffectedUser  `json:"affectedUsers"`
+	Violations        []string        `json:"violations"`
+	CanSave           bool            `json:"canSave"`
+}
+
+var RoleSortFields = map[string]string{
+	"createdAt": "created_at",
+	"name":      "name",
+	"level":     "level",
+}
+
+var RoleFeatureSortFields = map[string]string{
+	"id":        "id",
+	"roleId":    "role_id",
+	"featureId": "feature_id",
+}
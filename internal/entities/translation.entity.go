@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Translation overrides or extends the embedded message catalogs. Namespace
+// "message" keys are the English message texts, namespace "feature" keys are
+// feature menu slugs.
+type Translation struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;"`
+	Lang      string    `json:"lang" gorm:"type:varchar(16);not null;uniqueIndex:idx_translations_key"`
+	Namespace string    `json:"namespace" gorm:"type:varchar(32);not null;uniqueIndex:idx_translations_key"`
+	Key       string    `json:"key" gorm:"type:text;not null;uniqueIndex:idx_translations_key"`
+	Value     string    `json:"value" gorm:"type:text;not null"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy uuid.UUID `json:"updatedBy" gorm:"type:uuid"`
+}
+
+type ReqTranslation struct {
+	Lang      string `json:"lang"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+}
@@ -21,7 +21,12 @@ type User struct {
 	TwoFactorToken     string          `json:"twoFactorToken" gorm:"type:varchar;default:null;"`
 	TwoFactorAuthUrl   string          `json:"twoFactorAuthUrl" gorm:"type:varchar;default:null;"`
 	RoleId             *uuid.UUID      `json:"roleId" gorm:"type:uuid"`
+	RoleValidFrom      *time.Time      `json:"roleValidFrom"`
+	RoleValidUntil     *time.Time      `json:"roleValidUntil" gorm:"index"`
+	FallbackRoleId     *uuid.UUID      `json:"fallbackRoleId" gorm:"type:uuid"`
+	OrgUnitId          *uuid.UUID      `json:"orgUnitId" gorm:"type:uuid;index"`
 	Role               Role            `json:"role"`
+	Roles              []Role          `json:"roles" gorm:"-"`
 	ForgotPasswordCode string          `json:"forgotPasswordCode" gorm:"type:varchar"`
 	IsActive           *bool           `json:"isActive" gorm:"default:true"`
 	CreatedAt          time.Time       `json:"createdAt"`
@@ -32,28 +37,141 @@ type User struct {
 	DeletedBy          *uuid.UUID      `json:"-" gorm:"type:uuid;index;"`
 }
 
+// RoleLevel is the level used by the hierarchy checks: the highest level of
+// all the user's roles, or of the primary role when Roles is not loaded.
+func (u User) RoleLevel() int32 {
+	level := u.Role.Level
+	for _, role := range u.Roles {
+		if role.Level > level {
+			level = role.Level
+		}
+	}
+
+	return level
+}
+
+// RoleActiveAt reports whether the primary role is within its validity
+// window at t. Outside of it the fallback role applies instead.
+func (u User) RoleActiveAt(t time.Time) bool {
+	return activeAt(u.RoleValidFrom, u.RoleValidUntil, t)
+}
+
+func activeAt(from, until *time.Time, t time.Time) bool {
+	return (from == nil || !from.After(t)) && (until == nil || until.After(t))
+}
+
+// UserRole assigns an additional role to a user. RoleId on User stays the
+// primary role and is part of the user's roles while it is valid. Either
+// bound may be left empty.
+type UserRole struct {
+	UserId     uuid.UUID  `json:"userId" gorm:"type:uuid;primaryKey;"`
+	RoleId     uuid.UUID  `json:"roleId" gorm:"type:uuid;primaryKey;index;"`
+	ValidFrom  *time.Time `json:"validFrom"`
+	ValidUntil *time.Time `json:"validUntil" gorm:"index"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  uuid.UUID  `json:"createdBy" gorm:"type:uuid"`
+}
+
+// RoleAssignment is a role requested for a user with its optional window.
+type RoleAssignment struct {
+	RoleId     uuid.UUID  `json:"roleId"`
+	ValidFrom  *time.Time `json:"validFrom"`
+	ValidUntil *time.Time `json:"validUntil"`
+}
+
+// RoleExpiry records a role assignment ended by the expiry job.
+type RoleExpiry struct {
+	UserId         uuid.UUID  `json:"userId"`
+	RoleId         uuid.UUID  `json:"roleId"`
+	FallbackRoleId *uuid.UUID `json:"fallbackRoleId,omitempty"`
+	ValidUntil     time.Time  `json:"validUntil"`
+}
+
+const (
+	OverrideGrant = "grant"
+	OverrideDeny  = "deny"
+)
+
+// PermissionOverride grants or denies one action of a feature to a single
+// user regardless of what the user's roles allow. An override with ExpiresAt
+// in the past is kept for the record but no longer applied.
+type PermissionOverride struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;"`
+	UserId    uuid.UUID  `json:"userId" gorm:"type:uuid;not null;uniqueIndex:idx_permission_overrides_grant"`
+	FeatureId uuid.UUID  `json:"featureId" gorm:"type:uuid;not null;uniqueIndex:idx_permission_overrides_grant"`
+	Action    string     `json:"action" gorm:"type:varchar(50);not null;uniqueIndex:idx_permission_overrides_grant"`
+	Effect    string     `json:"effect" gorm:"type:varchar(10);not null"`
+	Reason    string     `json:"reason" gorm:"type:varchar"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	CreatedBy uuid.UUID  `json:"createdBy" gorm:"type:uuid"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	UpdatedBy uuid.UUID  `json:"updatedBy" gorm:"type:uuid"`
+}
+
+func (o PermissionOverride) ActiveAt(t time.Time) bool {
+	return o.ExpiresAt == nil || o.ExpiresAt.After(t)
+}
+
+type ReqPermissionOverride struct {
+	FeatureId uuid.UUID  `json:"featureId"`
+	Action    string     `json:"action"`
+	Effect    string     `json:"effect"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type ResPermissionOverride struct {
+	ID          uuid.UUID  `json:"id"`
+	FeatureId   uuid.UUID  `json:"featureId"`
+	FeatureName string     `json:"featureName"`
+	MenuSlug    string     `json:"menuSlug"`
+	Action      string     `json:"action"`
+	Effect      string     `json:"effect"`
+	Reason      string     `json:"reason"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	Expired     bool       `json:"expired"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CreatedBy   uuid.UUID  `json:"createdBy"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	UpdatedBy   uuid.UUID  `json:"updatedBy"`
+}
+
+type RoleInUser struct {
+	RoleId     uuid.UUID  `json:"roleId"`
+	RoleName   string     `json:"roleName"`
+	RoleLevel  int32      `json:"roleLevel"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
 type ReqUser struct {
-	ID                 uuid.UUID       `json:"id"`
-	FirstName          string          `json:"firstName"`
-	LastName           string          `json:"lastName"`
-	Email              string          `json:"email"`
-	PhoneNumber        string          `json:"phoneNumber"`
-	Password           string          `json:"password"`
-	ConfirmPassword    string          `json:"confirmPassword"`
-	Avatar             string          `json:"avatar"`
-	TwoFactorEnabled   *bool           `json:"twoFactorEnabled"`
-	TwoFactorVerified  *bool           `json:"twoFactorVerified"`
-	TwoFactorToken     string          `json:"twoFactorToken"`
-	TwoFactorAuthUrl   string          `json:"twoFactorAuthUrl"`
-	RoleId             *uuid.UUID      `json:"roleId"`
-	ForgotPasswordCode string          `json:"forgotPasswordCode"`
-	IsActive           *bool           `json:"isActive"`
-	CreatedAt          time.Time       `json:"createdAt"`
-	CreatedBy          uuid.UUID       `json:"createdBy"`
-	UpdatedAt          time.Time       `json:"updatedAt"`
-	UpdatedBy          uuid.UUID       `json:"updatedBy"`
-	DeletedAt          *gorm.DeletedAt `json:"-"`
-	DeletedBy          *uuid.UUID      `json:"-"`
+	ID                 uuid.UUID        `json:"id"`
+	FirstName          string           `json:"firstName"`
+	LastName           string           `json:"lastName"`
+	Email              string           `json:"email"`
+	PhoneNumber        string           `json:"phoneNumber"`
+	Password           string           `json:"password"`
+	ConfirmPassword    string           `json:"confirmPassword"`
+	Avatar             string           `json:"avatar"`
+	TwoFactorEnabled   *bool            `json:"twoFactorEnabled"`
+	TwoFactorVerified  *bool            `json:"twoFactorVerified"`
+	TwoFactorToken     string           `json:"twoFactorToken"`
+	TwoFactorAuthUrl   string           `json:"twoFactorAuthUrl"`
+	RoleId             *uuid.UUID       `json:"roleId"`
+	RoleIds            []uuid.UUID      `json:"roleIds"`
+	RoleValidFrom      *time.Time       `json:"roleValidFrom"`
+	RoleValidUntil     *time.Time       `json:"roleValidUntil"`
+	FallbackRoleId     *uuid.UUID       `json:"fallbackRoleId"`
+	RoleAssignments    []RoleAssignment `json:"roleAssignments"`
+	OrgUnitId          *uuid.UUID       `json:"orgUnitId"`
+	ForgotPasswordCode string           `json:"forgotPasswordCode"`
+	IsActive           *bool            `json:"isActive"`
+	CreatedAt          time.Time        `json:"createdAt"`
+	CreatedBy          uuid.UUID        `json:"createdBy"`
+	UpdatedAt          time.Time        `json:"updatedAt"`
+	UpdatedBy          uuid.UUID        `json:"updatedBy"`
+	DeletedAt          *gorm.DeletedAt  `json:"-"`
+	DeletedBy          *uuid.UUID       `json:"-"`
 }
 
 type ReqChangePassword struct {
@@ -74,21 +192,26 @@ type ResAllUserDTOs struct {
 }
 
 type ResUserDTO struct {
-	UserID            uuid.UUID `json:"userId"`
-	Email             string    `json:"email"`
-	FirstName         string    `json:"firstName"`
-	LastName          string    `json:"lastName"`
-	PhoneNumber       string    `json:"phoneNumber"`
-	Avatar            *string   `json:"avatar"`
-	RoleId            uuid.UUID `json:"roleId"`
-	RoleName          string    `json:"roleName"`
-	RoleLevel         int32     `json:"roleLevel"`
-	TwoFactorEnabled  bool      `json:"twoFactorEnabled"`
-	TwoFactorVerified bool      `json:"twoFactorVerified"`
-	TwoFactorAuthUrl  *string   `json:"twoFactorAuthUrl"`
-	TwoFactorToken    *string   `json:"twoFactorToken"`
+	UserID            uuid.UUID    `json:"userId"`
+	Email             string       `json:"email"`
+	FirstName         string       `json:"firstName"`
+	LastName          string       `json:"lastName"`
+	PhoneNumber       string       `json:"phoneNumber"`
+	Avatar            *string      `json:"avatar"`
+	RoleId            uuid.UUID    `json:"roleId"`
+	RoleName          string       `json:"roleName"`
+	RoleLevel         int32        `json:"roleLevel"`
+	RoleValidUntil    *time.Time   `json:"roleValidUntil"`
+	FallbackRoleId    *uuid.UUID   `json:"fallbackRoleId"`
+	OrgUnitId         *uuid.UUID   `json:"orgUnitId"`
+	Roles             []RoleInUser `json:"roles"`
+	TwoFactorEnabled  bool         `json:"twoFactorEnabled"`
+	TwoFactorVerified bool         `json:"twoFactorVerified"`
+	TwoFactorAuthUrl  *string      `json:"twoFactorAuthUrl"`
+	TwoFactorToken    *string      `json:"twoFactorToken"`
 	// Permission        []entities.Permission `json:"-"`
-	Features []FeatureDTODetails `json:"permissions"`
+	Features            []FeatureDTODetails     `json:"permissions"`
+	PermissionOverrides []ResPermissionOverride `json:"permissionOverrides"`
 }
 
 type ResUsersNoPage struct {
@@ -132,3 +255,11 @@ type ResUserProfile struct {
 	UserActivity     []interface{} `json:"userActivity"`
 	UserDevice       []interface{} `json:"userDevice"`
 }
+
+var UserSortFields = map[string]string{
+	"createdAt":   "created_at",
+	"firstName":   "first_name",
+	"lastName":    "last_name",
+	"email":       "email",
+	"phoneNumber": "phone_number",
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/usecases"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	HttpAuditHandler interface {
+		GetAuditLogsHandler(c *fiber.Ctx) error
+	}
+
+	httpAuditHandler struct {
+		auditUseCase usecases.AuditUsecase
+	}
+)
+
+func NewHttpAuditHandler(useCase usecases.AuditUsecase) HttpAuditHandler {
+	return &httpAuditHandler{auditUseCase: useCase}
+}
+
+func (h *httpAuditHandler) GetAuditLogsHandler(c *fiber.Ctx) error {
+	pq, err := helpers.ParsePageQuery(c, entities.AuditLogSortFields, "createdAt")
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	logs, err := h.auditUseCase.GetAuditLogs(pq, c.Query("entityType"), c.Query("entityId"))
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(logs)
+}
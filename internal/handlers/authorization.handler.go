@@ -142,9 +142,15 @@ func (h *httpAuthorizationHandler) GetAuthorizationByIdHandler(c *fiber.Ctx) err
 }
 
 func (h *httpAuthorizationHandler) GetAllAuthorizationsHandler(c *fiber.Ctx) error {
-	auths, err := h.authorizationUsecase.GetAllAuthorizations()
+	pq, err := helpers.ParsePageQuery(c, entities.AuthorizationSortFields, "createdAt")
 	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	auths, err := h.authorizationUsecase.GetAllAuthorizations(pq)
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(auths)
@@ -14,11 +14,17 @@ type (
 	HttpFeatureHandler interface {
 		CreateFeatureHandler(c *fiber.Ctx) error
 		GetFeatureByIdHandler(c *fiber.Ctx) error
+		GetFeatureBySlugHandler(c *fiber.Ctx) error
 		GetAllFeaturePermissionsHandler(c *fiber.Ctx) error
 		GetRefFeatureHandler(c *fiber.Ctx) error
 		GetAllFeaturesDefaultHandler(c *fiber.Ctx) error
 		UpdateFeatureHandler(c *fiber.Ctx) error
 		DeleteFeatureHandler(c *fiber.Ctx) error
+		GetFeatureDeletePreviewHandler(c *fiber.Ctx) error
+		RestoreFeatureHandler(c *fiber.Ctx) error
+		PurgeFeatureHandler(c *fiber.Ctx) error
+		MoveFeatureHandler(c *fiber.Ctx) error
+		ReorderFeaturesHandler(c *fiber.Ctx) error
 	}
 
 	httpFeatureHandler struct {
@@ -43,7 +49,7 @@ func (h *httpFeatureHandler) CreateFeatureHandler(c *fiber.Ctx) error {
 	}
 
 	feature.ID = uuid.New()
-	if err := h.featureUseCase.CreateFeature(feature, Iconfile); err != nil {
+	if err := h.featureUseCase.CreateFeature(c.UserContext(), feature, Iconfile); err != nil {
 		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
 	}
 
@@ -54,7 +60,7 @@ func (h *httpFeatureHandler) CreateFeatureHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpFeatureHandler) GetFeatureByIdHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
@@ -68,16 +74,35 @@ func (h *httpFeatureHandler) GetFeatureByIdHandler(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(feature)
 }
 
+func (h *httpFeatureHandler) GetFeatureBySlugHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	feature, err := h.featureUseCase.GetFeatureBySlug(ctx, c.Params("slug"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusNotFound, "Feature Not Found", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(feature)
+}
+
 func (h *httpFeatureHandler) GetAllFeaturePermissionsHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
-	features, err := h.featureUseCase.GetAllRoleFeatures(ctx)
+	ctx := c.UserContext()
+	pq, err := helpers.ParsePageQuery(c, entities.RoleFeatureSortFields, "id")
 	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	features, err := h.featureUseCase.GetAllRoleFeatures(ctx, pq)
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(features)
 }
 
+// GetRefFeatureHandler and GetAllFeaturesDefaultHandler are deliberately
+// unpaged: the menu tree and the role matrix need every feature at once.
+// Use GetAllFeaturePermissionsHandler for listings.
 func (h *httpFeatureHandler) GetRefFeatureHandler(c *fiber.Ctx) error {
 	features, err := h.featureUseCase.GetRefFeatures()
 	if err != nil {
@@ -97,7 +122,7 @@ func (h *httpFeatureHandler) GetAllFeaturesDefaultHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpFeatureHandler) UpdateFeatureHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
@@ -127,13 +152,19 @@ func (h *httpFeatureHandler) UpdateFeatureHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpFeatureHandler) DeleteFeatureHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
 	}
 
-	if err := h.featureUseCase.DeleteFeature(id); err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	delBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := h.featureUseCase.DeleteFeature(ctx, id, delBy, c.QueryBool("cascade")); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusConflict, "Conflict", err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -141,3 +172,91 @@ func (h *httpFeatureHandler) DeleteFeatureHandler(c *fiber.Ctx) error {
 		"deleted featureId": id,
 	})
 }
+
+func (h *httpFeatureHandler) GetFeatureDeletePreviewHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	preview, err := h.featureUseCase.GetFeatureDeletePreview(id)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusNotFound, "Feature Not Found", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preview)
+}
+
+func (h *httpFeatureHandler) RestoreFeatureHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	ids, err := h.featureUseCase.RestoreFeature(ctx, id)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":             "restore feature successful.",
+		"restored featureIds": ids,
+	})
+}
+
+func (h *httpFeatureHandler) PurgeFeatureHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	ids, err := h.featureUseCase.PurgeFeature(ctx, id)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":           "purge feature successful.",
+		"purged featureIds": ids,
+	})
+}
+
+func (h *httpFeatureHandler) MoveFeatureHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqFeatureMove
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	if err := h.featureUseCase.MoveFeature(ctx, id, req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":         "move feature successful.",
+		"moved featureId": id,
+	})
+}
+
+func (h *httpFeatureHandler) ReorderFeaturesHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	var req entities.ReqFeatureReorder
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	if err := h.featureUseCase.ReorderFeatures(ctx, req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "reorder features successful.",
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"work01/pkg"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	HttpHealthHandler interface {
+		LivenessHandler(c *fiber.Ctx) error
+		ReadinessHandler(c *fiber.Ctx) error
+	}
+
+	httpHealthHandler struct {
+		checker pkg.HealthChecker
+	}
+)
+
+func NewHttpHealthHandler(checker pkg.HealthChecker) HttpHealthHandler {
+	return &httpHealthHandler{checker: checker}
+}
+
+// LivenessHandler only tells that the process is answering.
+func (h *httpHealthHandler) LivenessHandler(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": pkg.HealthOk})
+}
+
+// ReadinessHandler answers 503 while a required dependency is down. A
+// degraded service still takes traffic.
+func (h *httpHealthHandler) ReadinessHandler(c *fiber.Ctx) error {
+	report := h.checker.Readiness(c.UserContext())
+	if report.Status == pkg.HealthUnavailable {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/usecases"
+)
+
+type (
+	HttpMenuHandler interface {
+		GetMenuHandler(c *fiber.Ctx) error
+		GetMenuPermissionHandler(c *fiber.Ctx) error
+		CheckPermissionsHandler(c *fiber.Ctx) error
+	}
+
+	httpMenuHandler struct {
+		permissionUseCase usecases.PermissionUsecase
+	}
+)
+
+func NewHttpMenuHandler(useCase usecases.PermissionUsecase) HttpMenuHandler {
+	return &httpMenuHandler{permissionUseCase: useCase}
+}
+
+func (h *httpMenuHandler) GetMenuHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	lang := helpers.Language(c)
+	c.Set(fiber.HeaderContentLanguage, lang)
+
+	menu, err := h.permissionUseCase.GetUserMenu(ctx, id, lang)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(menu)
+}
+
+func (h *httpMenuHandler) GetMenuPermissionHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	permission, err := h.permissionUseCase.GetUserPermissionBySlug(ctx, id, c.Params("slug"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	if permission == nil {
+		return helpers.ErrResponse(c, fiber.StatusNotFound, "Permission Not Found", "your role has no permission on this feature")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(permission)
+}
+
+// CheckPermissionsHandler answers each (feature, action) pair for the caller,
+// or for body.userId when the caller may inspect that user.
+func (h *httpMenuHandler) CheckPermissionsHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	actorId, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqPermissionCheck
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	userId := actorId
+	if req.UserId != nil {
+		userId = *req.UserId
+	}
+
+	decisions, err := h.permissionUseCase.CheckPermissionsFor(ctx, actorId, userId, req.Checks)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"userId":    userId,
+		"decisions": decisions,
+	})
+}
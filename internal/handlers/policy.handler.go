@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/usecases"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type (
+	HttpPolicyHandler interface {
+		GetPoliciesHandler(c *fiber.Ctx) error
+		CreatePolicyHandler(c *fiber.Ctx) error
+		UpdatePolicyHandler(c *fiber.Ctx) error
+		DeletePolicyHandler(c *fiber.Ctx) error
+		GetOrgUnitsHandler(c *fiber.Ctx) error
+		CreateOrgUnitHandler(c *fiber.Ctx) error
+		UpdateOrgUnitHandler(c *fiber.Ctx) error
+		DeleteOrgUnitHandler(c *fiber.Ctx) error
+	}
+
+	httpPolicyHandler struct {
+		policyUseCase usecases.PolicyUsecase
+	}
+)
+
+func NewHttpPolicyHandler(useCase usecases.PolicyUsecase) HttpPolicyHandler {
+	return &httpPolicyHandler{policyUseCase: useCase}
+}
+
+func (h *httpPolicyHandler) GetPoliciesHandler(c *fiber.Ctx) error {
+	policies, err := h.policyUseCase.GetPolicies()
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(policies)
+}
+
+func (h *httpPolicyHandler) CreatePolicyHandler(c *fiber.Ctx) error {
+	var req entities.ReqAccessPolicy
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	creBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	policy, err := h.policyUseCase.CreatePolicy(req, creBy)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "create access policy successful.",
+		"policy":  policy,
+	})
+}
+
+func (h *httpPolicyHandler) UpdatePolicyHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqAccessPolicy
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	updBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	policy, err := h.policyUseCase.UpdatePolicy(id, req, updBy)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "update access policy successful.",
+		"policy":  policy,
+	})
+}
+
+func (h *httpPolicyHandler) DeletePolicyHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	delBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := h.policyUseCase.DeletePolicy(id, delBy); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":          "delete access policy successful.",
+		"deleted policyId": id,
+	})
+}
+
+func (h *httpPolicyHandler) GetOrgUnitsHandler(c *fiber.Ctx) error {
+	units, err := h.policyUseCase.GetOrgUnits()
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(units)
+}
+
+func (h *httpPolicyHandler) CreateOrgUnitHandler(c *fiber.Ctx) error {
+	var req entities.ReqOrgUnit
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	creBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	unit, err := h.policyUseCase.CreateOrgUnit(req, creBy)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "create org unit successful.",
+		"orgUnit": unit,
+	})
+}
+
+func (h *httpPolicyHandler) UpdateOrgUnitHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqOrgUnit
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	updBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	unit, err := h.policyUseCase.UpdateOrgUnit(id, req, updBy)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "update org unit successful.",
+		"orgUnit": unit,
+	})
+}
+
+func (h *httpPolicyHandler) DeleteOrgUnitHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	delBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := h.policyUseCase.DeleteOrgUnit(id, delBy); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":           "delete org unit successful.",
+		"deleted orgUnitId": id,
+	})
+}
@@ -21,7 +21,12 @@ type (
 		GetAllRolesDefaultHandler(c *fiber.Ctx) error
 		GetAllRolesDropdownHandler(c *fiber.Ctx) error
 		UpdateRoleHandler(c *fiber.Ctx) error
+		PreviewUpdateRoleHandler(c *fiber.Ctx) error
 		DeleteRoleHandler(c *fiber.Ctx) error
+		CloneRoleHandler(c *fiber.Ctx) error
+		GetPermissionTemplatesHandler(c *fiber.Ctx) error
+		CreatePermissionTemplateHandler(c *fiber.Ctx) error
+		ApplyPermissionTemplateHandler(c *fiber.Ctx) error
 	}
 )
 
@@ -53,6 +58,7 @@ func (h *httpRoleHandler) CreateRoleHandler(c *fiber.Ctx) error {
 			IsView:    f.IsView,
 			IsEdit:    f.IsEdit,
 			IsDelete:  f.IsDelete,
+			Actions:   f.Actions,
 		})
 	}
 
@@ -70,7 +76,7 @@ func (h *httpRoleHandler) CreateRoleHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleHandler) GetRoleByIdHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
@@ -85,15 +91,23 @@ func (h *httpRoleHandler) GetRoleByIdHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleHandler) GetAllRolesModifyHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
-	roles, err := h.roleUseCase.GetAllRolesModify(ctx)
+	ctx := c.UserContext()
+	pq, err := helpers.ParsePageQuery(c, entities.RoleSortFields, "createdAt")
 	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	roles, err := h.roleUseCase.GetAllRolesModify(ctx, pq)
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(roles)
 }
 
+// GetAllRolesDefaultHandler is deliberately unpaged: it fills pickers that
+// need every role at once. Use GetAllRolesModifyHandler for listings.
 func (h *httpRoleHandler) GetAllRolesDefaultHandler(c *fiber.Ctx) error {
 	roles, err := h.roleUseCase.GetAllRolesDefault()
 	if err != nil {
@@ -104,7 +118,7 @@ func (h *httpRoleHandler) GetAllRolesDefaultHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleHandler) GetAllRolesDropdownHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	roles, err := h.roleUseCase.GetAllRolesDropdown(ctx)
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
@@ -114,7 +128,7 @@ func (h *httpRoleHandler) GetAllRolesDropdownHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleHandler) UpdateRoleHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
@@ -135,7 +149,69 @@ func (h *httpRoleHandler) UpdateRoleHandler(c *fiber.Ctx) error {
 		Level: roleReq.Level,
 	}
 
+	role.ID = id
+	role.UpdatedBy = updBy
+	res, err := h.roleUseCase.UpdateRole(ctx, &role, roleFeaturesOf(roleReq))
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Role Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "update role successful.",
+		"updated roleId": role.ID,
+		"role":           res,
+	})
+}
+
+// PreviewUpdateRoleHandler takes the body of UpdateRoleHandler and reports
+// what saving it would do without saving.
+func (h *httpRoleHandler) PreviewUpdateRoleHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var roleReq entities.ReqRoleUpdate
+	if err := c.BodyParser(&roleReq); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	updBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	role := entities.Role{
+		ID:        id,
+		Name:      roleReq.Name,
+		Level:     roleReq.Level,
+		UpdatedBy: updBy,
+	}
+
+	preview, err := h.roleUseCase.PreviewUpdateRole(ctx, &role, roleFeaturesOf(roleReq))
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Role Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
+	}
+
+	lang := helpers.Language(c)
+	for i, v := range preview.Violations {
+		preview.Violations[i] = helpers.Translate(lang, v)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(preview)
+}
+
+// roleFeaturesOf converts the matrix of an update request. An omitted
+// features list leaves the matrix untouched; an empty one removes every
+// permission of the role.
+func roleFeaturesOf(roleReq entities.ReqRoleUpdate) []entities.RoleFeature {
 	var roleFeatures []entities.RoleFeature
+	if roleReq.Features != nil {
+		roleFeatures = make([]entities.RoleFeature, 0, len(roleReq.Features))
+	}
 	for _, f := range roleReq.Features {
 		roleFeatures = append(roleFeatures, entities.RoleFeature{
 			FeatureId: f.FeatureId,
@@ -143,39 +219,135 @@ func (h *httpRoleHandler) UpdateRoleHandler(c *fiber.Ctx) error {
 			IsView:    f.IsView,
 			IsEdit:    f.IsEdit,
 			IsDelete:  f.IsDelete,
+			Actions:   f.Actions,
 		})
 	}
 
-	role.ID = id
-	role.UpdatedBy = updBy
-	if err := h.roleUseCase.UpdateRole(ctx, &role, roleFeatures); err != nil {
+	return roleFeatures
+}
+
+func (h *httpRoleHandler) DeleteRoleHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	delBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	var reassignTo *uuid.UUID
+	if q := c.Query("reassignTo"); q != "" {
+		toRoleId, err := uuid.Parse(q)
+		if err != nil {
+			return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+		}
+		reassignTo = &toRoleId
+	}
+
+	reassignment, err := h.roleUseCase.DeleteRole(ctx, id, reassignTo, delBy)
+	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message":        "update role successful.",
-		"updated roleId": role.ID,
+	res := fiber.Map{
+		"message":        "detele role successful.",
+		"deleted roleId": id,
+	}
+	if reassignment != nil {
+		res["reassignment"] = reassignment
+	}
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *httpRoleHandler) CloneRoleHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqRoleClone
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	creBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	role, err := h.roleUseCase.CloneRole(ctx, id, req, creBy)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "clone role successful.",
+		"role":    role,
 	})
 }
 
-func (h *httpRoleHandler) DeleteRoleHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+func (h *httpRoleHandler) GetPermissionTemplatesHandler(c *fiber.Ctx) error {
+	templates, err := h.roleUseCase.GetPermissionTemplates()
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(templates)
+}
+
+func (h *httpRoleHandler) CreatePermissionTemplateHandler(c *fiber.Ctx) error {
+	var template entities.PermissionTemplate
+	if err := c.BodyParser(&template); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	creBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	template.ID = uuid.New()
+	template.CreatedBy = creBy
+	if err := h.roleUseCase.CreatePermissionTemplate(template); err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":          "create permission template successful.",
+		"created template": template.Name,
+	})
+}
+
+func (h *httpRoleHandler) ApplyPermissionTemplateHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
 	}
 
-	delBy, err := uuid.Parse(c.Locals("userId").(string))
+	var req entities.ReqApplyTemplate
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	updBy, err := uuid.Parse(c.Locals("userId").(string))
 	if err != nil {
 		return err
 	}
 
-	if err := h.roleUseCase.DeleteRole(ctx, id, delBy); err != nil {
+	role, err := h.roleUseCase.ApplyPermissionTemplate(ctx, id, req, updBy)
+	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message":        "detele role successful.",
-		"deleted roleId": id,
+		"message": "apply permission template successful.",
+		"role":    role,
 	})
 }
@@ -45,7 +45,7 @@ func (h *httpRoleFeatureHandler) CreateRoleFeatureHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleFeatureHandler) GetRoleFeatureByIdHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
@@ -60,10 +60,16 @@ func (h *httpRoleFeatureHandler) GetRoleFeatureByIdHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpRoleFeatureHandler) GetAllRoleFeaturesHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
-	roleFeatures, err := h.roleFeatureUseCase.GetAllRoleFeatures(ctx)
+	ctx := c.UserContext()
+	pq, err := helpers.ParsePageQuery(c, entities.RoleFeatureSortFields, "id")
 	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	roleFeatures, err := h.roleFeatureUseCase.GetAllRoleFeatures(ctx, pq)
+	if err != nil {
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(roleFeatures)
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/usecases"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type (
+	HttpTranslationHandler interface {
+		GetLanguagesHandler(c *fiber.Ctx) error
+		GetTranslationsHandler(c *fiber.Ctx) error
+		SaveTranslationHandler(c *fiber.Ctx) error
+	}
+
+	httpTranslationHandler struct {
+		translationUseCase usecases.TranslationUsecase
+	}
+)
+
+func NewHttpTranslationHandler(useCase usecases.TranslationUsecase) HttpTranslationHandler {
+	return &httpTranslationHandler{translationUseCase: useCase}
+}
+
+func (h *httpTranslationHandler) GetLanguagesHandler(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(h.translationUseCase.GetLanguages())
+}
+
+func (h *httpTranslationHandler) GetTranslationsHandler(c *fiber.Ctx) error {
+	translations, err := h.translationUseCase.GetTranslations(c.Params("lang"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(translations)
+}
+
+func (h *httpTranslationHandler) SaveTranslationHandler(c *fiber.Ctx) error {
+	var req entities.ReqTranslation
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	updBy, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := h.translationUseCase.SaveTranslation(req, updBy); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "save translation successful.",
+	})
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"mime/multipart"
-	"strconv"
 	"work01/internal/entities"
 	"work01/internal/helpers"
 	"work01/internal/usecases"
@@ -21,6 +20,9 @@ type (
 		UpdateUserHandler(c *fiber.Ctx) error
 		ChangePsswordHandler(c *fiber.Ctx) error
 		DeleteUserHandler(c *fiber.Ctx) error
+		GetPermissionOverridesHandler(c *fiber.Ctx) error
+		SavePermissionOverrideHandler(c *fiber.Ctx) error
+		DeletePermissionOverrideHandler(c *fiber.Ctx) error
 	}
 
 	httpUserHandler struct {
@@ -51,7 +53,7 @@ func (h *httpUserHandler) CreateUserHandler(c *fiber.Ctx) error {
 
 	user.ID = uuid.New()
 	user.CreatedBy = creBy
-	if err := h.userUseCase.CreateUser(user, avatarfile); err != nil {
+	if err := h.userUseCase.CreateUser(c.UserContext(), user, avatarfile); err != nil {
 		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
 	}
 
@@ -68,7 +70,7 @@ func (h *httpUserHandler) CreateUserHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpUserHandler) GetUserByIdHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Locals("userId").(string))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
@@ -97,13 +99,8 @@ func (h *httpUserHandler) GetUserProfileByIdHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpUserHandler) GetAllUsersWithPageHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
-	}
-
-	size, err := strconv.Atoi(c.Query("size", "10"))
+	ctx := c.UserContext()
+	pq, err := helpers.ParsePageQuery(c, entities.UserSortFields, "createdAt")
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
 	}
@@ -113,14 +110,17 @@ func (h *httpUserHandler) GetAllUsersWithPageHandler(c *fiber.Ctx) error {
 	phoneNumber := c.Query("phoneNumber", "")
 	fullName := c.Query("fullName", "")
 
-	users, err := h.userUseCase.GetAllUsersWithPage(ctx, page, size, roleId, isActive, phoneNumber, fullName)
+	users, err := h.userUseCase.GetAllUsersWithPage(ctx, pq, roleId, isActive, phoneNumber, fullName)
 	if err != nil {
-		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+		status, title := helpers.ErrStatus(err, "Not Found")
+		return helpers.ErrResponse(c, status, title, err.Error())
 	}
 
 	return c.Status(fiber.StatusOK).JSON(users)
 }
 
+// GetAllUsersNoPageHandler is deliberately unpaged: it fills pickers that
+// need every user at once. Use GetAllUsersWithPageHandler for listings.
 func (h *httpUserHandler) GetAllUsersNoPageHandler(c *fiber.Ctx) error {
 	users, err := h.userUseCase.GetAllUsersNoPage()
 	if err != nil {
@@ -131,7 +131,7 @@ func (h *httpUserHandler) GetAllUsersNoPageHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpUserHandler) UpdateUserHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
@@ -166,7 +166,7 @@ func (h *httpUserHandler) UpdateUserHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpUserHandler) ChangePsswordHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
@@ -195,7 +195,7 @@ func (h *httpUserHandler) ChangePsswordHandler(c *fiber.Ctx) error {
 }
 
 func (h *httpUserHandler) DeleteUserHandler(c *fiber.Ctx) error {
-	ctx := c.Context()
+	ctx := c.UserContext()
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
@@ -215,3 +215,75 @@ func (h *httpUserHandler) DeleteUserHandler(c *fiber.Ctx) error {
 		"deleted userId": id,
 	})
 }
+
+func (h *httpUserHandler) GetPermissionOverridesHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	managerId, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	overrides, err := h.userUseCase.GetPermissionOverrides(managerId, id)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(overrides)
+}
+
+func (h *httpUserHandler) SavePermissionOverrideHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	var req entities.ReqPermissionOverride
+	if err := c.BodyParser(&req); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request Body", err.Error())
+	}
+
+	managerId, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	overrides, err := h.userUseCase.SavePermissionOverride(managerId, id, req)
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":             "save permission override successful.",
+		"permissionOverrides": overrides,
+	})
+}
+
+func (h *httpUserHandler) DeletePermissionOverrideHandler(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	overrideId, err := uuid.Parse(c.Params("overrideId"))
+	if err != nil {
+		return helpers.ErrResponse(c, fiber.StatusBadRequest, "Bad Request", err.Error())
+	}
+
+	managerId, err := uuid.Parse(c.Locals("userId").(string))
+	if err != nil {
+		return err
+	}
+
+	if err := h.userUseCase.DeletePermissionOverride(managerId, id, overrideId); err != nil {
+		return helpers.ErrResponse(c, fiber.StatusInternalServerError, "Internal Server Error", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"message":            "delete permission override successful.",
+		"deleted overrideId": overrideId,
+	})
+}
@@ -1,17 +1,27 @@
 package helpers
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"time"
 	"work01/config"
 	"work01/internal/entities"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// AccessTokenTTL is how long an access token stays valid, and so how long a
+// revoked one has to stay blocked.
+const AccessTokenTTL = time.Minute * 15
+
 func loadPrivateKey() *rsa.PrivateKey {
 	if err := config.LoadConfig(); err != nil {
 		return nil
@@ -64,7 +74,7 @@ func GenerateToken(user *entities.User) (*entities.AuthToken, error) {
 	claims["firstNmae"] = user.FirstName
 	claims["lastName"] = user.LastName
 	claims["roleName"] = user.Role.Name
-	claims["exp"] = time.Now().Add(time.Minute * 15).Unix()
+	claims["exp"] = time.Now().Add(AccessTokenTTL).Unix()
 
 	t, err := token.SignedString(privateKey)
 	if err != nil {
@@ -105,3 +115,81 @@ func ValidateToken(tokenString string) (*jwt.Token, error) {
 
 	return token, err
 }
+
+// SigningKeyRotation reports where the new key pair was written and where
+// the previous one was kept.
+type SigningKeyRotation struct {
+	PrivateKeyPath         string `json:"privateKeyPath"`
+	PublicKeyPath          string `json:"publicKeyPath"`
+	PreviousPrivateKeyPath string `json:"previousPrivateKeyPath,omitempty"`
+	PreviousPublicKeyPath  string `json:"previousPublicKeyPath,omitempty"`
+	RotatedAt              string `json:"rotatedAt"`
+}
+
+// RotateSigningKeys writes a new RSA key pair over the configured key files,
+// renaming the old files with a timestamp suffix. Tokens signed with the old
+// key stop validating, so every user has to sign in again.
+func RotateSigningKeys() (*SigningKeyRotation, error) {
+	if err := config.LoadConfig(); err != nil {
+		log.Printf("failed to read .env, using the environment only: %v", err)
+	}
+
+	cfg := config.ReadInConfig()
+	if cfg.PRIVATE_KEY == "" || cfg.PUBLIC_KEY == "" {
+		return nil, fmt.Errorf("PRIVATE_KEY_PATH and PUBLIC_KEY_PATH must be set")
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	now := time.Now()
+	rotation := SigningKeyRotation{PrivateKeyPath: cfg.PRIVATE_KEY, PublicKeyPath: cfg.PUBLIC_KEY, RotatedAt: now.Format(time.RFC3339)}
+
+	suffix := fmt.Sprintf(".%d.bak", now.Unix())
+	for _, key := range []struct {
+		path     string
+		previous *string
+	}{
+		{cfg.PRIVATE_KEY, &rotation.PreviousPrivateKeyPath},
+		{cfg.PUBLIC_KEY, &rotation.PreviousPublicKeyPath},
+	} {
+		if _, err := os.Stat(key.path); err != nil {
+			continue
+		}
+		if err := os.Rename(key.path, key.path+suffix); err != nil {
+			return nil, err
+		}
+		*key.previous = key.path + suffix
+	}
+
+	if err := os.WriteFile(cfg.PRIVATE_KEY, privatePEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cfg.PUBLIC_KEY, publicPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	return &rotation, nil
+}
+
+type actorKey struct{}
+
+// WithActor records the authenticated caller of a gRPC request.
+func WithActor(ctx context.Context, userId uuid.UUID) context.Context {
+	return context.WithValue(ctx, actorKey{}, userId)
+}
+
+// ActorFromContext returns the caller recorded by WithActor, if any.
+func ActorFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userId, ok := ctx.Value(actorKey{}).(uuid.UUID)
+	return userId, ok
+}
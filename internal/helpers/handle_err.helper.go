@@ -1,12 +1,58 @@
 package helpers
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ErrForbidden and ErrInvalid mark errors, through Mark, that ErrStatus
+// reports as 403 and 400.
+var (
+	ErrForbidden = errors.New("forbidden")
+	ErrInvalid   = errors.New("invalid request")
 )
 
+type markedError struct {
+	err  error
+	mark error
+}
+
+func (e markedError) Error() string { return e.err.Error() }
+
+func (e markedError) Unwrap() []error { return []error{e.err, e.mark} }
+
+// Mark tags err with mark and keeps its message, so it is still translated
+// as it is.
+func Mark(err error, mark error) error {
+	if err == nil {
+		return nil
+	}
+
+	return markedError{err: err, mark: mark}
+}
+
+// ErrStatus picks the response status and title for err.
+func ErrStatus(err error, notFound string) (int, string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.StatusNotFound, notFound
+	case errors.Is(err, ErrForbidden):
+		return fiber.StatusForbidden, "Forbidden"
+	case errors.Is(err, ErrInvalid):
+		return fiber.StatusBadRequest, "Bad Request"
+	}
+
+	return fiber.StatusInternalServerError, "Internal Server Error"
+}
+
 func ErrResponse(c *fiber.Ctx, statusCode int, titleError string, errorDetails string) error {
+	lang := Language(c)
+	c.Set(fiber.HeaderContentLanguage, lang)
+
 	return c.Status(statusCode).JSON(fiber.Map{
-		"error":   titleError,
-		"message": errorDetails,
+		"error":   Translate(lang, titleError),
+		"message": Translate(lang, errorDetails),
 	})
 }
@@ -0,0 +1,283 @@
+package helpers
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	DefaultLanguage = "en"
+
+	MessageNamespace = "message"
+	FeatureNamespace = "feature"
+)
+
+type languageKey struct{}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// formatVerb matches the verbs used in catalog keys. Keys are the English
+// format strings passed to fmt.Errorf, so a message needs no separate id and
+// untranslated languages simply fall back to the English text.
+var formatVerb = regexp.MustCompile(`%[dsvq]`)
+
+type messagePattern struct {
+	re  *regexp.Regexp
+	key string
+}
+
+// localizer holds the translations of every known language, built from the
+// embedded catalogs and then overlaid with rows from the translations table.
+type localizer struct {
+	mu           sync.RWMutex
+	translations map[string]map[string]map[string]string
+	patterns     []messagePattern
+}
+
+var i18n = newLocalizer()
+
+func newLocalizer() *localizer {
+	l := &localizer{translations: map[string]map[string]map[string]string{}}
+
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range files {
+		b, err := localeFiles.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			panic(fmt.Sprintf("invalid locale file %s: %v", f.Name(), err))
+		}
+
+		lang := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		for key, value := range messages {
+			l.add(lang, MessageNamespace, key, value)
+		}
+	}
+
+	return l
+}
+
+func (l *localizer) add(lang, namespace, key, value string) {
+	lang = strings.ToLower(lang)
+	if l.translations[lang] == nil {
+		l.translations[lang] = map[string]map[string]string{}
+	}
+	if l.translations[lang][namespace] == nil {
+		l.translations[lang][namespace] = map[string]string{}
+	}
+
+	if namespace == MessageNamespace && formatVerb.MatchString(key) {
+		if !l.hasPattern(key) {
+			l.patterns = append(l.patterns, messagePattern{re: compileFormat(key), key: key})
+		}
+	}
+
+	l.translations[lang][namespace][key] = value
+}
+
+func (l *localizer) hasPattern(key string) bool {
+	for _, p := range l.patterns {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// compileFormat turns a format string into a regexp capturing each argument,
+// so an already rendered error message can be matched back to its key.
+func compileFormat(format string) *regexp.Regexp {
+	parts := formatVerb.Split(format, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "(.+?)") + "$")
+}
+
+func (l *localizer) lookup(lang, namespace, key string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	value, ok := l.translations[lang][namespace][key]
+	return value, ok
+}
+
+func (l *localizer) translateMessage(lang, message string) string {
+	if value, ok := l.lookup(lang, MessageNamespace, message); ok {
+		return value
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	catalog := l.translations[lang][MessageNamespace]
+	for _, p := range l.patterns {
+		value, ok := catalog[p.key]
+		if !ok {
+			continue
+		}
+
+		m := p.re.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+
+		args := make([]interface{}, len(m)-1)
+		for i, arg := range m[1:] {
+			args[i] = arg
+		}
+		return fmt.Sprintf(formatVerb.ReplaceAllString(value, "%s"), args...)
+	}
+
+	return message
+}
+
+// AddTranslation registers value as the text of key in lang. Languages do not
+// need to be declared first; adding any translation makes lang selectable.
+func AddTranslation(lang, namespace, key, value string) {
+	i18n.mu.Lock()
+	defer i18n.mu.Unlock()
+
+	i18n.add(lang, namespace, key, value)
+}
+
+// Languages lists every selectable language, the default one first.
+func Languages() []string {
+	i18n.mu.RLock()
+	defer i18n.mu.RUnlock()
+
+	langs := []string{DefaultLanguage}
+	for lang := range i18n.translations {
+		if lang != DefaultLanguage {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+
+	return langs
+}
+
+func isSupportedLanguage(lang string) bool {
+	if lang == DefaultLanguage {
+		return true
+	}
+
+	i18n.mu.RLock()
+	defer i18n.mu.RUnlock()
+
+	_, ok := i18n.translations[lang]
+	return ok
+}
+
+// ParseLanguage picks the preferred supported language from an
+// Accept-Language value such as "th-TH,th;q=0.9,en;q=0.8". A region is
+// tried as given first and then without it.
+func ParseLanguage(header string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		candidates = append(candidates, weighted{lang: lang, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if isSupportedLanguage(c.lang) {
+			return c.lang
+		}
+		if base, _, ok := strings.Cut(c.lang, "-"); ok && isSupportedLanguage(base) {
+			return base
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// Language resolves the caller's language from the lang query parameter or
+// the Accept-Language header.
+func Language(c *fiber.Ctx) string {
+	if lang := c.Query("lang"); lang != "" {
+		return ParseLanguage(lang)
+	}
+
+	return ParseLanguage(c.Get(fiber.HeaderAcceptLanguage))
+}
+
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+func LanguageFromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok && lang != "" {
+		return lang
+	}
+
+	return DefaultLanguage
+}
+
+// Translate returns message in lang, or message itself when no catalog has
+// it. Messages produced from a format string are matched against that format
+// and re-rendered with the same arguments.
+func Translate(lang, message string) string {
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+
+	return i18n.translateMessage(lang, message)
+}
+
+// FeatureName picks the display name of a feature for lang: a row from the
+// translations table keyed by slug wins, then the built-in Thai and English
+// menu names, then the feature name.
+func FeatureName(lang, slug, nameTh, nameEn, name string) string {
+	if value, ok := i18n.lookup(lang, FeatureNamespace, slug); ok && value != "" {
+		return value
+	}
+
+	if lang == "th" && nameTh != "" {
+		return nameTh
+	}
+
+	if nameEn != "" {
+		return nameEn
+	}
+
+	return name
+}
@@ -0,0 +1,56 @@
+package helpers
+
+import "testing"
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty", "", DefaultLanguage},
+		{"single", "th", "th"},
+		{"region falls back to its language", "th-TH", "th"},
+		{"case is ignored", "TH-th", "th"},
+		{"highest weight wins", "en;q=0.5,th;q=0.9", "th"},
+		{"order breaks ties", "th,en", "th"},
+		{"unsupported languages are skipped", "fr-FR,de;q=0.9,th;q=0.8", "th"},
+		{"wildcard", "*", DefaultLanguage},
+		{"nothing supported", "fr,de", DefaultLanguage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseLanguage(tt.header); got != tt.want {
+				t.Errorf("ParseLanguage(%q) = %s, want %s", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name    string
+		lang    string
+		message string
+		want    string
+	}{
+		{"exact match", "th", "feature not found", "ไม่พบฟีเจอร์"},
+		{"default language is left as it is", "en", "feature not found", "feature not found"},
+		{"empty language is the default", "", "feature not found", "feature not found"},
+		{"unknown message", "th", "no such message", "no such message"},
+		{"format with a number", "th", "size can not be more than 100", "size ต้องไม่เกิน 100"},
+		{"format with a string", "th", "can not sort by password", "ไม่สามารถเรียงลำดับตาม password ได้"},
+		{"format with two numbers", "th", "your role level (1) must be higher than the role level (5) you are attempting to create for user", "ระดับบทบาทของคุณ (1) ต้องสูงกว่าระดับบทบาท (5) ที่ต้องการกำหนดให้ผู้ใช้ใหม่"},
+		{"format with two strings", "th", "action export is not available on feature reports", "การกระทำ export ไม่มีในฟีเจอร์ reports"},
+		{"format must match the whole message", "th", "you can not sort by password", "you can not sort by password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Translate(tt.lang, tt.message); got != tt.want {
+				t.Errorf("Translate(%s, %q) = %q, want %q", tt.lang, tt.message, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "work01"
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC calls by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of GORM statements by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table", "status"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by key family and result.",
+	}, []string{"family", "result"})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	tokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_refreshes_total",
+		Help:      "Access token refreshes by result.",
+	}, []string{"result"})
+
+	cacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_invalidation_messages_total",
+		Help:      "Cache eviction messages published to or received from other instances.",
+	}, []string{"direction"})
+
+	cacheInvalidationKeys = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_invalidation_keys_total",
+		Help:      "Cache keys evicted through the invalidation bus.",
+	}, []string{"direction"})
+
+	cacheInvalidationLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_invalidation_lag_seconds",
+		Help:      "Time between an eviction being published and applied on another instance.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5},
+	})
+
+	uploadSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "minio_upload_size_bytes",
+		Help:      "Size of objects uploaded to MinIO.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 8),
+	}, []string{"kind"})
+)
+
+func outcome(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failure"
+}
+
+func ObserveHTTPRequest(method string, route string, status int, elapsed time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+func ObserveGRPCRequest(method string, code string, elapsed time.Duration) {
+	grpcRequestDuration.WithLabelValues(method, code).Observe(elapsed.Seconds())
+}
+
+func ObserveDBQuery(operation string, table string, failed bool, elapsed time.Duration) {
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+	dbQueryDuration.WithLabelValues(operation, table, status).Observe(elapsed.Seconds())
+}
+
+func RecordCacheLookup(family string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(family, result).Inc()
+}
+
+func RecordLogin(ok bool) {
+	logins.WithLabelValues(outcome(ok)).Inc()
+}
+
+func RecordTokenRefresh(ok bool) {
+	tokenRefreshes.WithLabelValues(outcome(ok)).Inc()
+}
+
+func RecordInvalidationPublished(keys int) {
+	cacheInvalidations.WithLabelValues("published").Inc()
+	cacheInvalidationKeys.WithLabelValues("published").Add(float64(keys))
+}
+
+func ObserveInvalidationReceived(keys int, lag time.Duration) {
+	cacheInvalidations.WithLabelValues("received").Inc()
+	cacheInvalidationKeys.WithLabelValues("received").Add(float64(keys))
+	cacheInvalidationLag.Observe(lag.Seconds())
+}
+
+func ObserveUpload(kind string, size int64) {
+	uploadSize.WithLabelValues(kind).Observe(float64(size))
+}
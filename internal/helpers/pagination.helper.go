@@ -1,22 +1,55 @@
 package helpers
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"math"
-	"work01/internal/entities"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
 )
 
-type Pagination struct {
-	Page      int                       `json:"page"`
-	TotalPage int                       `json:"totalPage"`
-	Size      int                       `json:"size"`
-	Total     int64                     `json:"total"`
-	Items     []entities.ResAllUserDTOs `json:"items"`
+type Pagination[T any] struct {
+	Page       int    `json:"page"`
+	TotalPage  int    `json:"totalPage"`
+	Size       int    `json:"size"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Items      []T    `json:"items"`
+}
+
+// PageQuery is the normalized form of the page/size/sort/order/cursor query
+// parameters shared by every list endpoint. Sort always holds a column name
+// taken from the whitelist passed to NewPageQuery, never raw client input.
+type PageQuery struct {
+	Page      int
+	Size      int
+	Sort      string
+	Order     string
+	UseCursor bool
+	Cursor    uuid.UUID
+}
+
+func (q PageQuery) Offset() int {
+	return (q.Page - 1) * q.Size
 }
 
-func Pagiante(page, size int, total int64, items []entities.ResAllUserDTOs) Pagination {
+func (q PageQuery) Desc() bool {
+	return q.Order == "desc"
+}
+
+func Pagiante[T any](page, size int, total int64, items []T) Pagination[T] {
 	totalPage := int(math.Ceil(float64(total) / float64(size)))
 
-	return Pagination{
+	return Pagination[T]{
 		Page:      page,
 		TotalPage: totalPage,
 		Size:      size,
@@ -24,3 +57,111 @@ func Pagiante(page, size int, total int64, items []entities.ResAllUserDTOs) Pagi
 		Items:     items,
 	}
 }
+
+// PaginateCursor builds the response for keyset mode, where page and total
+// are not computed so that large tables are never fully scanned.
+func PaginateCursor[T any](size int, nextCursor string, items []T) Pagination[T] {
+	return Pagination[T]{
+		Size:       size,
+		NextCursor: nextCursor,
+		Items:      items,
+	}
+}
+
+// NewPagination picks the offset or keyset response shape from pq.
+func NewPagination[T any](pq PageQuery, total int64, nextCursor string, items []T) Pagination[T] {
+	if pq.UseCursor {
+		return PaginateCursor(pq.Size, nextCursor, items)
+	}
+
+	return Pagiante(pq.Page, pq.Size, total, items)
+}
+
+// NewPageQuery validates raw list parameters. sortable maps the public field
+// names accepted in "sort" to their column names; defaultSort must be one of
+// its keys. Cursor mode is enabled with mode=cursor or a non-empty cursor.
+func NewPageQuery(page, size int, sort, order, mode, cursor string, sortable map[string]string, defaultSort string) (PageQuery, error) {
+	if page < 1 {
+		return PageQuery{}, fmt.Errorf("page must be greater than 0")
+	}
+
+	if size < 1 {
+		return PageQuery{}, fmt.Errorf("size must be greater than 0")
+	}
+
+	if size > MaxPageSize {
+		return PageQuery{}, fmt.Errorf("size can not be more than %d", MaxPageSize)
+	}
+
+	if sort == "" {
+		sort = defaultSort
+	}
+
+	column, ok := sortable[sort]
+	if !ok {
+		return PageQuery{}, fmt.Errorf("can not sort by %s", sort)
+	}
+
+	order = strings.ToLower(order)
+	if order == "" {
+		order = "asc"
+	}
+
+	if order != "asc" && order != "desc" {
+		return PageQuery{}, fmt.Errorf("order must be asc or desc")
+	}
+
+	q := PageQuery{
+		Page:      page,
+		Size:      size,
+		Sort:      column,
+		Order:     order,
+		UseCursor: mode == "cursor" || cursor != "",
+	}
+
+	if cursor != "" {
+		id, err := DecodeCursor(cursor)
+		if err != nil {
+			return PageQuery{}, err
+		}
+		q.Cursor = id
+	}
+
+	return q, nil
+}
+
+func ParsePageQuery(c *fiber.Ctx, sortable map[string]string, defaultSort string) (PageQuery, error) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil {
+		return PageQuery{}, fmt.Errorf("page must be a number")
+	}
+
+	size, err := strconv.Atoi(c.Query("size", strconv.Itoa(DefaultPageSize)))
+	if err != nil {
+		return PageQuery{}, fmt.Errorf("size must be a number")
+	}
+
+	return NewPageQuery(page, size, c.Query("sort"), c.Query("order"), c.Query("mode"), c.Query("cursor"), sortable, defaultSort)
+}
+
+// ErrStaleCursor is returned for a cursor whose row no longer exists, so the
+// position it marked in the ordering is lost.
+var ErrStaleCursor = Mark(errors.New("cursor is stale, start again from the first page"), ErrInvalid)
+
+func EncodeCursor(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func DecodeCursor(cursor string) (uuid.UUID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("cursor is invalid")
+	}
+
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("cursor is invalid")
+	}
+
+	return id, nil
+}
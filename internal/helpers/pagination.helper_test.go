@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewPageQuery(t *testing.T) {
+	sortable := map[string]string{"name": "name", "createdAt": "created_at"}
+	id := uuid.New()
+
+	tests := []struct {
+		name    string
+		page    int
+		size    int
+		sort    string
+		order   string
+		mode    string
+		cursor  string
+		want    PageQuery
+		wantErr string
+	}{
+		{name: "defaults", page: 1, size: 10,
+			want: PageQuery{Page: 1, Size: 10, Sort: "name", Order: "asc"}},
+		{name: "sort is mapped to its column", page: 2, size: 5, sort: "createdAt", order: "DESC",
+			want: PageQuery{Page: 2, Size: 5, Sort: "created_at", Order: "desc"}},
+		{name: "cursor mode", page: 1, size: 10, mode: "cursor",
+			want: PageQuery{Page: 1, Size: 10, Sort: "name", Order: "asc", UseCursor: true}},
+		{name: "a cursor enables cursor mode", page: 1, size: 10, cursor: EncodeCursor(id),
+			want: PageQuery{Page: 1, Size: 10, Sort: "name", Order: "asc", UseCursor: true, Cursor: id}},
+		{name: "page below one", page: 0, size: 10, wantErr: "page must be greater than 0"},
+		{name: "size below one", page: 1, size: 0, wantErr: "size must be greater than 0"},
+		{name: "size above the maximum", page: 1, size: MaxPageSize + 1, wantErr: "size can not be more than 100"},
+		{name: "unknown sort", page: 1, size: 10, sort: "password", wantErr: "can not sort by password"},
+		{name: "column names are not accepted", page: 1, size: 10, sort: "created_at", wantErr: "can not sort by created_at"},
+		{name: "bad order", page: 1, size: 10, order: "up", wantErr: "order must be asc or desc"},
+		{name: "bad cursor", page: 1, size: 10, cursor: "not a cursor", wantErr: "cursor is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPageQuery(tt.page, tt.size, tt.sort, tt.order, tt.mode, tt.cursor, sortable, "name")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("NewPageQuery() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPageQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NewPageQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    uuid.UUID
+		wantErr bool
+	}{
+		{"round trip", EncodeCursor(id), id, false},
+		{"not base64", "***", uuid.Nil, true},
+		{"wrong length", "YWJj", uuid.Nil, true},
+		{"padded base64", EncodeCursor(id) + "==", uuid.Nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeCursor() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const MaxSlugLength = 100
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidateSlug accepts lowercase letters and digits in groups joined by
+// single hyphens, the form the frontend routes use.
+func ValidateSlug(slug string) error {
+	if slug == "" {
+		return fmt.Errorf("menu slug cannot be empty")
+	}
+
+	if len(slug) > MaxSlugLength {
+		return fmt.Errorf("menu slug can not be longer than %d characters", MaxSlugLength)
+	}
+
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("menu slug may only contain lowercase letters, digits and single hyphens between them")
+	}
+
+	return nil
+}
+
+const MaxActionLength = 50
+
+// ValidateAction accepts action names in the same form as slugs, such as
+// "export" or "bulk-approve".
+func ValidateAction(action string) error {
+	if action == "" {
+		return fmt.Errorf("action cannot be empty")
+	}
+
+	if len(action) > MaxActionLength {
+		return fmt.Errorf("action can not be longer than %d characters", MaxActionLength)
+	}
+
+	if !slugPattern.MatchString(action) {
+		return fmt.Errorf("action %s may only contain lowercase letters, digits and single hyphens between them", action)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("Load() found no migrations")
+	}
+
+	tests := []struct {
+		name  string
+		check func(i int, m Migration) string
+	}{
+		{"versions start at one and have no gaps", func(i int, m Migration) string {
+			if m.Version != int64(i+1) {
+				return "is out of sequence"
+			}
+			return ""
+		}},
+		{"every migration has a name", func(i int, m Migration) string {
+			if m.Name == "" {
+				return "has no name"
+			}
+			return ""
+		}},
+		{"every migration has an up and a down", func(i int, m Migration) string {
+			if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+				return "is missing a direction"
+			}
+			return ""
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, m := range migrations {
+				if problem := tt.check(i, m); problem != "" {
+					t.Errorf("migration %d_%s %s", m.Version, m.Name, problem)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"work01/internal/entities"
+	"work01/internal/repositories"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SeedOptions describes the bootstrap admin. No user is created when
+// AdminEmail is empty.
+type SeedOptions struct {
+	AdminEmail    string
+	AdminPassword string
+	AdminPhone    string
+}
+
+type seedFeature struct {
+	Slug   string
+	Parent string
+	Icon   string
+	NameTh string
+	NameEn string
+}
+
+// baseFeatures is the menu every installation starts with. Parents come
+// before their children.
+var baseFeatures = []seedFeature{
+	{Slug: "dashboard", Icon: "dashboard", NameTh: "แดชบอร์ด", NameEn: "Dashboard"},
+	{Slug: "administration", Icon: "settings", NameTh: "การจัดการระบบ", NameEn: "Administration"},
+	{Slug: "users", Parent: "administration", Icon: "users", NameTh: "ผู้ใช้งาน", NameEn: "Users"},
+	{Slug: "roles", Parent: "administration", Icon: "shield", NameTh: "บทบาท", NameEn: "Roles"},
+	{Slug: "features", Parent: "administration", Icon: "menu", NameTh: "เมนู", NameEn: "Features"},
+	{Slug: "permission-templates", Parent: "administration", Icon: "template", NameTh: "แม่แบบสิทธิ์", NameEn: "Permission Templates"},
+	{Slug: "access-policies", Parent: "administration", Icon: "policy", NameTh: "นโยบายการเข้าถึง", NameEn: "Access Policies"},
+	{Slug: "org-units", Parent: "administration", Icon: "sitemap", NameTh: "หน่วยงาน", NameEn: "Org Units"},
+	{Slug: "translations", Parent: "administration", Icon: "language", NameTh: "คำแปล", NameEn: "Translations"},
+	{Slug: "audit-logs", Parent: "administration", Icon: "history", NameTh: "บันทึกการใช้งาน", NameEn: "Audit Logs"},
+}
+
+// Seed creates the base feature tree, the super administrator role with
+// every action on every feature and, when asked, the bootstrap admin. It
+// only adds what is missing, so it is safe to run on every start.
+func Seed(db *gorm.DB, opts SeedOptions) error {
+	if err := repositories.MarkSystemRoles(db); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := seedFeatures(tx); err != nil {
+			return err
+		}
+
+		roleId, err := seedSuperAdministrator(tx)
+		if err != nil {
+			return err
+		}
+
+		return seedAdmin(tx, roleId, opts)
+	})
+}
+
+func seedFeatures(tx *gorm.DB) error {
+	ids := map[string]uuid.UUID{}
+	seq := map[string]int32{}
+	for _, f := range baseFeatures {
+		seq[f.Parent]++
+
+		var feature entities.Feature
+		err := tx.Where("menu_slug = ?", f.Slug).First(&feature).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			active := true
+			feature = entities.Feature{
+				ID:         uuid.New(),
+				Name:       f.NameEn,
+				MenuIcon:   f.Icon,
+				MenuNameTh: f.NameTh,
+				MenuNameEn: f.NameEn,
+				MenuSlug:   f.Slug,
+				MenuSeqNo:  seq[f.Parent],
+				IsActive:   &active,
+			}
+			if f.Parent != "" {
+				parentId := ids[f.Parent]
+				feature.ParentMenuId = &parentId
+			}
+
+			if err := tx.Create(&feature).Error; err != nil {
+				return err
+			}
+		}
+		ids[f.Slug] = feature.ID
+
+		for _, action := range entities.StandardActions {
+			if err := tx.Exec(`INSERT INTO feature_actions (feature_id, action) VALUES (?, ?) ON CONFLICT DO NOTHING`, feature.ID, action).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedSuperAdministrator makes sure the super administrator role exists and
+// is granted every action declared on every feature.
+func seedSuperAdministrator(tx *gorm.DB) (uuid.UUID, error) {
+	var role entities.Role
+	err := tx.Where("system_key = ?", entities.SystemRoleSuperAdministrator).First(&role).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		key := entities.SystemRoleSuperAdministrator
+		role = entities.Role{ID: uuid.New(), Name: "Super Administrator", Level: 100, Version: 1, SystemKey: &key}
+		if err := tx.Create(&role).Error; err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	if err := tx.Exec(`INSERT INTO role_features (id, role_id, feature_id, is_add, is_view, is_edit, is_delete)
+		SELECT gen_random_uuid(), ?, features.id, true, true, true, true FROM features
+		WHERE features.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM role_features WHERE role_features.role_id = ? AND role_features.feature_id = features.id)`,
+		role.ID, role.ID).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Exec(`INSERT INTO role_feature_actions (role_feature_id, action)
+		SELECT role_features.id, feature_actions.action FROM role_features
+		JOIN feature_actions ON feature_actions.feature_id = role_features.feature_id
+		WHERE role_features.role_id = ?
+		ON CONFLICT DO NOTHING`, role.ID).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	return role.ID, nil
+}
+
+func seedAdmin(tx *gorm.DB, roleId uuid.UUID, opts SeedOptions) error {
+	if opts.AdminEmail == "" {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&entities.User{}).Where("email = ?", opts.AdminEmail).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if opts.AdminPassword == "" {
+		return fmt.Errorf("a password is required to create the admin user")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(opts.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	active := true
+	id := uuid.New()
+	return tx.Create(&entities.User{
+		ID:          id,
+		FirstName:   "System",
+		LastName:    "Administrator",
+		Email:       opts.AdminEmail,
+		PhoneNumber: opts.AdminPhone,
+		Password:    string(hashedPassword),
+		RoleId:      &roleId,
+		IsActive:    &active,
+		CreatedBy:   id,
+		UpdatedBy:   id,
+	}).Error
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS authorizations;
+DROP TABLE IF EXISTS role_features;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS features;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,73 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id uuid PRIMARY KEY,
+    name text NOT NULL,
+    level integer NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid,
+    deleted_at timestamptz,
+    deleted_by uuid
+);
+CREATE INDEX IF NOT EXISTS idx_roles_deleted_by ON roles (deleted_by);
+
+CREATE TABLE IF NOT EXISTS features (
+    id uuid PRIMARY KEY,
+    name varchar,
+    parent_menu_id uuid,
+    menu_icon varchar,
+    menu_name_th varchar,
+    menu_name_en varchar,
+    menu_slug varchar,
+    menu_seq_no varchar,
+    is_active boolean DEFAULT true
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid PRIMARY KEY,
+    first_name varchar NOT NULL,
+    last_name varchar NOT NULL,
+    email varchar NOT NULL,
+    phone_number varchar NOT NULL,
+    password varchar NOT NULL,
+    avatar varchar DEFAULT NULL,
+    two_factor_enabled boolean NOT NULL DEFAULT false,
+    two_factor_verified boolean NOT NULL DEFAULT false,
+    two_factor_token varchar DEFAULT NULL,
+    two_factor_auth_url varchar DEFAULT NULL,
+    role_id uuid CONSTRAINT fk_roles_users REFERENCES roles (id),
+    forgot_password_code varchar,
+    is_active boolean DEFAULT true,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid,
+    deleted_at timestamptz,
+    deleted_by uuid
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_by ON users (deleted_by);
+
+CREATE TABLE IF NOT EXISTS role_features (
+    id uuid,
+    role_id uuid CONSTRAINT fk_role_features_role REFERENCES roles (id),
+    feature_id uuid CONSTRAINT fk_role_features_feature REFERENCES features (id),
+    is_add boolean DEFAULT false,
+    is_view boolean DEFAULT false,
+    is_edit boolean DEFAULT false,
+    is_delete boolean DEFAULT false,
+    PRIMARY KEY (id, role_id, feature_id)
+);
+
+CREATE TABLE IF NOT EXISTS authorizations (
+    id uuid PRIMARY KEY,
+    user_id uuid,
+    access_token text,
+    refresh_token text,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid,
+    deleted_at timestamptz,
+    deleted_by uuid
+);
+CREATE INDEX IF NOT EXISTS idx_authorizations_deleted_by ON authorizations (deleted_by);
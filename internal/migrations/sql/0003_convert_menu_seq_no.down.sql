@@ -0,0 +1,3 @@
+ALTER TABLE features ALTER COLUMN menu_seq_no DROP NOT NULL;
+ALTER TABLE features ALTER COLUMN menu_seq_no DROP DEFAULT;
+ALTER TABLE features ALTER COLUMN menu_seq_no TYPE varchar USING menu_seq_no::varchar;
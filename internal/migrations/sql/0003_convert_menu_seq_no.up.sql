@@ -0,0 +1,11 @@
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'features' AND column_name = 'menu_seq_no') <> 'integer' THEN
+        ALTER TABLE features ALTER COLUMN menu_seq_no TYPE integer USING NULLIF(trim(menu_seq_no), '')::integer;
+    END IF;
+END $$;
+
+UPDATE features SET menu_seq_no = 0 WHERE menu_seq_no IS NULL;
+ALTER TABLE features ALTER COLUMN menu_seq_no SET DEFAULT 0;
+ALTER TABLE features ALTER COLUMN menu_seq_no SET NOT NULL;
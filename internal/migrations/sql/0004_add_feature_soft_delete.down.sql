@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_features_deleted_by;
+ALTER TABLE features DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE features DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE features ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE features ADD COLUMN IF NOT EXISTS deleted_by uuid;
+CREATE INDEX IF NOT EXISTS idx_features_deleted_by ON features (deleted_by);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_features_menu_slug;
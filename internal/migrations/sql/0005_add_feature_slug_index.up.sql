@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_features_menu_slug ON features (menu_slug) WHERE deleted_at IS NULL;
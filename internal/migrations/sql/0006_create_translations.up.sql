@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS translations (
+    id uuid PRIMARY KEY,
+    lang varchar(16) NOT NULL,
+    namespace varchar(32) NOT NULL,
+    key text NOT NULL,
+    value text NOT NULL,
+    updated_at timestamptz,
+    updated_by uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_translations_key ON translations (lang, namespace, key);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS permission_templates (
+    id uuid PRIMARY KEY,
+    name varchar NOT NULL,
+    description varchar,
+    is_add boolean DEFAULT false,
+    is_view boolean DEFAULT false,
+    is_edit boolean DEFAULT false,
+    is_delete boolean DEFAULT false,
+    created_at timestamptz,
+    created_by uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_templates_name ON permission_templates (name);
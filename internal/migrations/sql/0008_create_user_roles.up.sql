@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id uuid,
+    role_id uuid,
+    created_at timestamptz,
+    created_by uuid,
+    PRIMARY KEY (user_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
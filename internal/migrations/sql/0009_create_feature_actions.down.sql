@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS role_feature_actions;
+DROP TABLE IF EXISTS feature_actions;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS feature_actions (
+    feature_id uuid,
+    action varchar(50),
+    PRIMARY KEY (feature_id, action)
+);
+
+CREATE TABLE IF NOT EXISTS role_feature_actions (
+    role_feature_id uuid,
+    action varchar(50),
+    PRIMARY KEY (role_feature_id, action)
+);
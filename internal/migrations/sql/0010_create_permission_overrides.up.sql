@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS permission_overrides (
+    id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    feature_id uuid NOT NULL,
+    action varchar(50) NOT NULL,
+    effect varchar(10) NOT NULL,
+    reason varchar,
+    expires_at timestamptz,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_overrides_grant ON permission_overrides (user_id, feature_id, action);
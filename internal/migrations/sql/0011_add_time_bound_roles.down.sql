@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_user_roles_valid_until;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS valid_until;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS valid_from;
+
+DROP INDEX IF EXISTS idx_users_role_valid_until;
+ALTER TABLE users DROP COLUMN IF EXISTS fallback_role_id;
+ALTER TABLE users DROP COLUMN IF EXISTS role_valid_until;
+ALTER TABLE users DROP COLUMN IF EXISTS role_valid_from;
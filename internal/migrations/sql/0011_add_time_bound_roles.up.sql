@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role_valid_from timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role_valid_until timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS fallback_role_id uuid;
+CREATE INDEX IF NOT EXISTS idx_users_role_valid_until ON users (role_valid_until);
+
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS valid_from timestamptz;
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS valid_until timestamptz;
+CREATE INDEX IF NOT EXISTS idx_user_roles_valid_until ON user_roles (valid_until);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS access_policies;
+
+DROP INDEX IF EXISTS idx_users_org_unit_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_unit_id;
+
+DROP TABLE IF EXISTS org_units;
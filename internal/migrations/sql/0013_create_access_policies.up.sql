@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS org_units (
+    id uuid PRIMARY KEY,
+    name varchar NOT NULL,
+    parent_id uuid,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid,
+    deleted_at timestamptz,
+    deleted_by uuid
+);
+CREATE INDEX IF NOT EXISTS idx_org_units_parent_id ON org_units (parent_id);
+CREATE INDEX IF NOT EXISTS idx_org_units_deleted_by ON org_units (deleted_by);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_unit_id uuid;
+CREATE INDEX IF NOT EXISTS idx_users_org_unit_id ON users (org_unit_id);
+
+CREATE TABLE IF NOT EXISTS access_policies (
+    id uuid PRIMARY KEY,
+    name varchar NOT NULL,
+    description varchar,
+    resource varchar(50) NOT NULL,
+    action varchar(50) NOT NULL,
+    effect varchar(10) NOT NULL,
+    conditions text,
+    message varchar,
+    is_active boolean DEFAULT true,
+    created_at timestamptz,
+    created_by uuid,
+    updated_at timestamptz,
+    updated_by uuid,
+    deleted_at timestamptz,
+    deleted_by uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_access_policies_name ON access_policies (name);
+CREATE INDEX IF NOT EXISTS idx_access_policies_target ON access_policies (resource, action);
+CREATE INDEX IF NOT EXISTS idx_access_policies_deleted_by ON access_policies (deleted_by);
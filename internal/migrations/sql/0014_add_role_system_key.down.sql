@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_roles_system_key;
+ALTER TABLE roles DROP COLUMN IF EXISTS system_key;
@@ -0,0 +1,2 @@
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS system_key varchar(50);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_system_key ON roles (system_key);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_role_features_role_feature;
+DROP INDEX IF EXISTS idx_users_phone_number;
+DROP INDEX IF EXISTS idx_users_email;
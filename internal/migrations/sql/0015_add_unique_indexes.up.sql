@@ -0,0 +1,3 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone_number ON users (phone_number) WHERE deleted_at IS NULL AND phone_number <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_features_role_feature ON role_features (role_id, feature_id);
@@ -19,11 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UserGrpcService_CreateUser_FullMethodName     = "/proto.UserGrpcService/CreateUser"
-	UserGrpcService_GetUserById_FullMethodName    = "/proto.UserGrpcService/GetUserById"
-	UserGrpcService_GetAllUser_FullMethodName     = "/proto.UserGrpcService/GetAllUser"
-	UserGrpcService_UpdateUserById_FullMethodName = "/proto.UserGrpcService/UpdateUserById"
-	UserGrpcService_DeleteUserById_FullMethodName = "/proto.UserGrpcService/DeleteUserById"
+	UserGrpcService_CreateUser_FullMethodName       = "/proto.UserGrpcService/CreateUser"
+	UserGrpcService_GetUserById_FullMethodName      = "/proto.UserGrpcService/GetUserById"
+	UserGrpcService_GetAllUser_FullMethodName       = "/proto.UserGrpcService/GetAllUser"
+	UserGrpcService_UpdateUserById_FullMethodName   = "/proto.UserGrpcService/UpdateUserById"
+	UserGrpcService_DeleteUserById_FullMethodName   = "/proto.UserGrpcService/DeleteUserById"
+	UserGrpcService_GetUserMenu_FullMethodName      = "/proto.UserGrpcService/GetUserMenu"
+	UserGrpcService_CheckPermissions_FullMethodName = "/proto.UserGrpcService/CheckPermissions"
 )
 
 // UserGrpcServiceClient is the client API for UserGrpcService service.
@@ -35,6 +37,8 @@ type UserGrpcServiceClient interface {
 	GetAllUser(ctx context.Context, in *GetAllUserReq, opts ...grpc.CallOption) (*GetAllUserRes, error)
 	UpdateUserById(ctx context.Context, in *UpdateUserByIdReq, opts ...grpc.CallOption) (*UpdateUserByIdRes, error)
 	DeleteUserById(ctx context.Context, in *DeleteUserByIdReq, opts ...grpc.CallOption) (*DeleteUserByIdRes, error)
+	GetUserMenu(ctx context.Context, in *GetUserMenuReq, opts ...grpc.CallOption) (*GetUserMenuRes, error)
+	CheckPermissions(ctx context.Context, in *CheckPermissionsReq, opts ...grpc.CallOption) (*CheckPermissionsRes, error)
 }
 
 type userGrpcServiceClient struct {
@@ -95,6 +99,26 @@ func (c *userGrpcServiceClient) DeleteUserById(ctx context.Context, in *DeleteUs
 	return out, nil
 }
 
+func (c *userGrpcServiceClient) GetUserMenu(ctx context.Context, in *GetUserMenuReq, opts ...grpc.CallOption) (*GetUserMenuRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserMenuRes)
+	err := c.cc.Invoke(ctx, UserGrpcService_GetUserMenu_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userGrpcServiceClient) CheckPermissions(ctx context.Context, in *CheckPermissionsReq, opts ...grpc.CallOption) (*CheckPermissionsRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckPermissionsRes)
+	err := c.cc.Invoke(ctx, UserGrpcService_CheckPermissions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserGrpcServiceServer is the server API for UserGrpcService service.
 // All implementations must embed UnimplementedUserGrpcServiceServer
 // for forward compatibility.
@@ -104,6 +128,8 @@ type UserGrpcServiceServer interface {
 	GetAllUser(context.Context, *GetAllUserReq) (*GetAllUserRes, error)
 	UpdateUserById(context.Context, *UpdateUserByIdReq) (*UpdateUserByIdRes, error)
 	DeleteUserById(context.Context, *DeleteUserByIdReq) (*DeleteUserByIdRes, error)
+	GetUserMenu(context.Context, *GetUserMenuReq) (*GetUserMenuRes, error)
+	CheckPermissions(context.Context, *CheckPermissionsReq) (*CheckPermissionsRes, error)
 	mustEmbedUnimplementedUserGrpcServiceServer()
 }
 
@@ -129,6 +155,12 @@ func (UnimplementedUserGrpcServiceServer) UpdateUserById(context.Context, *Updat
 func (UnimplementedUserGrpcServiceServer) DeleteUserById(context.Context, *DeleteUserByIdReq) (*DeleteUserByIdRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserById not implemented")
 }
+func (UnimplementedUserGrpcServiceServer) GetUserMenu(context.Context, *GetUserMenuReq) (*GetUserMenuRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserMenu not implemented")
+}
+func (UnimplementedUserGrpcServiceServer) CheckPermissions(context.Context, *CheckPermissionsReq) (*CheckPermissionsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckPermissions not implemented")
+}
 func (UnimplementedUserGrpcServiceServer) mustEmbedUnimplementedUserGrpcServiceServer() {}
 func (UnimplementedUserGrpcServiceServer) testEmbeddedByValue()                         {}
 
@@ -240,6 +272,42 @@ func _UserGrpcService_DeleteUserById_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserGrpcService_GetUserMenu_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserMenuReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserGrpcServiceServer).GetUserMenu(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserGrpcService_GetUserMenu_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserGrpcServiceServer).GetUserMenu(ctx, req.(*GetUserMenuReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserGrpcService_CheckPermissions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckPermissionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserGrpcServiceServer).CheckPermissions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserGrpcService_CheckPermissions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserGrpcServiceServer).CheckPermissions(ctx, req.(*CheckPermissionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserGrpcService_ServiceDesc is the grpc.ServiceDesc for UserGrpcService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -267,6 +335,14 @@ var UserGrpcService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteUserById",
 			Handler:    _UserGrpcService_DeleteUserById_Handler,
 		},
+		{
+			MethodName: "GetUserMenu",
+			Handler:    _UserGrpcService_GetUserMenu_Handler,
+		},
+		{
+			MethodName: "CheckPermissions",
+			Handler:    _UserGrpcService_CheckPermissions_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/user.proto",
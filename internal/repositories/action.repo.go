@@ -0,0 +1,272 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"work01/internal/entities"
+	"work01/internal/helpers"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// declaredActions returns the actions each of featureIds can be granted.
+// Features without declarations predate them and offer the standard four.
+func declaredActions(db *gorm.DB, featureIds []uuid.UUID) (map[uuid.UUID][]string, error) {
+	var rows []entities.FeatureAction
+	if err := db.Where("feature_id IN ?", featureIds).Order("action").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	declared := make(map[uuid.UUID][]string, len(featureIds))
+	for _, row := range rows {
+		declared[row.FeatureId] = append(declared[row.FeatureId], row.Action)
+	}
+
+	for _, id := range featureIds {
+		if _, ok := declared[id]; !ok {
+			declared[id] = entities.StandardActions
+		}
+	}
+
+	return declared, nil
+}
+
+// loadFeatureActions fills Actions of each feature with its declared actions.
+func loadFeatureActions(db *gorm.DB, features []entities.Feature) error {
+	if len(features) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(features))
+	for _, f := range features {
+		ids = append(ids, f.ID)
+	}
+
+	declared, err := declaredActions(db, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range features {
+		features[i].Actions = declared[features[i].ID]
+	}
+
+	return nil
+}
+
+// saveFeatureActions replaces the declared actions of featureId. Grants of
+// actions that are no longer declared are revoked along with user overrides of
+// them, and the roles holding them get a new version.
+func saveFeatureActions(tx *gorm.DB, featureId uuid.UUID, actions []string) error {
+	if err := tx.Where("feature_id = ?", featureId).Delete(&entities.FeatureAction{}).Error; err != nil {
+		return err
+	}
+
+	rows := make([]entities.FeatureAction, 0, len(actions))
+	for _, action := range actions {
+		rows = append(rows, entities.FeatureAction{FeatureId: featureId, Action: action})
+	}
+
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+
+	roleFeatureIds := tx.Model(&entities.RoleFeature{}).Select("id").Where("feature_id = ?", featureId)
+
+	revoked := tx.Where("role_feature_id IN (?)", roleFeatureIds)
+	if len(actions) > 0 {
+		revoked = revoked.Where("action NOT IN ?", actions)
+	}
+	if err := revoked.Delete(&entities.RoleFeatureAction{}).Error; err != nil {
+		return err
+	}
+
+	overrides := tx.Where("feature_id = ?", featureId)
+	if len(actions) > 0 {
+		overrides = overrides.Where("action NOT IN ?", actions)
+	}
+	if err := overrides.Delete(&entities.PermissionOverride{}).Error; err != nil {
+		return err
+	}
+
+	flags := map[string]interface{}{}
+	for i, column := range []string{"is_view", "is_add", "is_edit", "is_delete"} {
+		if !entities.HasAction(actions, entities.StandardActions[i]) {
+			flags[column] = false
+		}
+	}
+	if len(flags) > 0 {
+		if err := tx.Model(&entities.RoleFeature{}).Where("feature_id = ?", featureId).Updates(flags).Error; err != nil {
+			return err
+		}
+	}
+
+	return bumpRoleVersionsByFeatures(tx, []uuid.UUID{featureId})
+}
+
+// grantedActions returns the granted actions of every feature in the matrix
+// of roleId. Rows written before per-action grants fall back to their flags.
+func grantedActions(db *gorm.DB, roleId uuid.UUID) (map[uuid.UUID][]string, error) {
+	var roleFeatures []entities.RoleFeature
+	if err := db.Where("role_id = ?", roleId).Find(&roleFeatures).Error; err != nil {
+		return nil, err
+	}
+
+	if err := loadRoleFeatureActions(db, roleFeatures); err != nil {
+		return nil, err
+	}
+
+	granted := make(map[uuid.UUID][]string, len(roleFeatures))
+	for _, rf := range roleFeatures {
+		granted[rf.FeatureId] = rf.Actions
+	}
+
+	return granted, nil
+}
+
+// loadRoleFeatureActions fills Actions of each row from role_feature_actions.
+func loadRoleFeatureActions(db *gorm.DB, roleFeatures []entities.RoleFeature) error {
+	if len(roleFeatures) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(roleFeatures))
+	for _, rf := range roleFeatures {
+		ids = append(ids, rf.ID)
+	}
+
+	var rows []entities.RoleFeatureAction
+	if err := db.Where("role_feature_id IN ?", ids).Order("action").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	actions := make(map[uuid.UUID][]string, len(roleFeatures))
+	for _, row := range rows {
+		actions[row.RoleFeatureId] = append(actions[row.RoleFeatureId], row.Action)
+	}
+
+	for i := range roleFeatures {
+		roleFeatures[i].Actions = actions[roleFeatures[i].ID]
+		if roleFeatures[i].Actions == nil {
+			roleFeatures[i].Actions = entities.ActionsFromFlags(roleFeatures[i].IsView, roleFeatures[i].IsAdd, roleFeatures[i].IsEdit, roleFeatures[i].IsDelete)
+		}
+	}
+
+	return nil
+}
+
+// saveRoleFeatureActions replaces the per-action grants of roleFeatures.
+func saveRoleFeatureActions(tx *gorm.DB, roleFeatures []entities.RoleFeature) error {
+	if len(roleFeatures) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(roleFeatures))
+	var rows []entities.RoleFeatureAction
+	for _, rf := range roleFeatures {
+		ids = append(ids, rf.ID)
+		for _, action := range rf.Actions {
+			rows = append(rows, entities.RoleFeatureAction{RoleFeatureId: rf.ID, Action: action})
+		}
+	}
+
+	if err := tx.Where("role_feature_id IN ?", ids).Delete(&entities.RoleFeatureAction{}).Error; err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return tx.Create(&rows).Error
+}
+
+// checkActionsDeclared rejects grants of actions the feature does not offer.
+func checkActionsDeclared(tx *gorm.DB, roleFeatures []entities.RoleFeature) error {
+	if len(roleFeatures) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(roleFeatures))
+	for _, rf := range roleFeatures {
+		ids = append(ids, rf.FeatureId)
+	}
+
+	declared, err := declaredActions(tx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, rf := range roleFeatures {
+		for _, action := range rf.Actions {
+			if !entities.HasAction(declared[rf.FeatureId], action) {
+				return helpers.Mark(fmt.Errorf("action %s is not available on feature %s", action, rf.FeatureId), helpers.ErrInvalid)
+			}
+		}
+	}
+
+	return nil
+}
+
+func sameActions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// unionActions merges action lists, keeping each action once in sorted order.
+func unionActions(lists ...[]string) []string {
+	union := []string{}
+	for _, list := range lists {
+		for _, action := range list {
+			if !entities.HasAction(union, action) {
+				union = append(union, action)
+			}
+		}
+	}
+	sort.Strings(union)
+
+	return union
+}
+
+// MigrateLegacyPermissions backfills the action tables from the boolean
+// columns: every feature declares the standard actions and every granted flag
+// becomes a grant row. Existing rows are kept, so it is safe to run on every
+// start.
+func MigrateLegacyPermissions(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO feature_actions (feature_id, action)
+			SELECT features.id, actions.action FROM features
+			CROSS JOIN (VALUES ('view'), ('add'), ('edit'), ('delete')) AS actions(action)
+			WHERE NOT EXISTS (SELECT 1 FROM feature_actions WHERE feature_actions.feature_id = features.id)
+			ON CONFLICT DO NOTHING`).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`INSERT INTO role_feature_actions (role_feature_id, action)
+			SELECT role_features.id, actions.action FROM role_features
+			CROSS JOIN LATERAL (VALUES
+				('view', role_features.is_view),
+				('add', role_features.is_add),
+				('edit', role_features.is_edit),
+				('delete', role_features.is_delete)) AS actions(action, granted)
+			WHERE actions.granted
+			AND NOT EXISTS (SELECT 1 FROM role_feature_actions WHERE role_feature_actions.role_feature_id = role_features.id)
+			ON CONFLICT DO NOTHING`).Error
+	})
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSameActions(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want bool
+	}{
+		{"both empty", nil, []string{}, true},
+		{"same order", []string{"view", "edit"}, []string{"view", "edit"}, true},
+		{"order is ignored", []string{"edit", "view"}, []string{"view", "edit"}, true},
+		{"different length", []string{"view"}, []string{"view", "edit"}, false},
+		{"different action", []string{"view", "add"}, []string{"view", "edit"}, false},
+		{"duplicates count", []string{"view", "view"}, []string{"view", "edit"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := append([]string(nil), tt.a...)
+			b := append([]string(nil), tt.b...)
+
+			if got := sameActions(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameActions() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(a, tt.a) || !slices.Equal(b, tt.b) {
+				t.Errorf("sameActions() reordered its arguments")
+			}
+		})
+	}
+}
+
+func TestUnionActions(t *testing.T) {
+	tests := []struct {
+		name  string
+		lists [][]string
+		want  []string
+	}{
+		{"nothing", nil, []string{}},
+		{"one list is sorted", [][]string{{"view", "add"}}, []string{"add", "view"}},
+		{"lists are merged", [][]string{{"view"}, {"edit", "view"}, nil}, []string{"edit", "view"}},
+		{"duplicates are dropped", [][]string{{"export", "export"}}, []string{"export"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unionActions(tt.lists...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unionActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
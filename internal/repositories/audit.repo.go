@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"encoding/json"
+	"work01/internal/entities"
+	"work01/internal/helpers"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	AuditRepository interface {
+		GetAll(pq helpers.PageQuery, entityType string, entityId string) ([]entities.AuditLog, int64, string, error)
+	}
+
+	auditRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) GetAll(pq helpers.PageQuery, entityType string, entityId string) ([]entities.AuditLog, int64, string, error) {
+	var logs []entities.AuditLog
+	var total int64
+	var cursor string
+
+	query := r.db.Model(&entities.AuditLog{})
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityId != "" {
+		query = query.Where("entity_id = ?", entityId)
+	}
+
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	if err := query.Scopes(pageScope("audit_logs", pq)).Find(&logs).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	if pq.UseCursor {
+		logs, cursor = nextCursor(logs, pq.Size, func(log entities.AuditLog) uuid.UUID { return log.ID })
+	}
+
+	return logs, total, cursor, nil
+}
+
+// recordAudit appends an entry to the audit trail within tx. actorId is nil
+// for changes made by the system itself.
+func recordAudit(tx *gorm.DB, action string, entityType string, entityId uuid.UUID, actorId *uuid.UUID, details interface{}) error {
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&entities.AuditLog{
+		ID:         uuid.New(),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		ActorId:    actorId,
+		Details:    string(b),
+	}).Error
+}
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"time"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 
 	"github.com/go-redis/cache/v9"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -16,7 +16,7 @@ type (
 	AuthorizationRepository interface {
 		Create(auth *entities.Authorization) error
 		GetById(id uuid.UUID) (*entities.Authorization, error)
-		GetAll() ([]entities.Authorization, error)
+		GetAll(pq helpers.PageQuery) ([]entities.Authorization, int64, string, error)
 		Update(auth *entities.Authorization) error
 		Delete(id uuid.UUID, deleteBy uuid.UUID) error
 		GetUserById(id uuid.UUID) (*entities.User, error)
@@ -31,16 +31,12 @@ type (
 
 	authorizationRepository struct {
 		db         *gorm.DB
-		redisCache *cache.Cache
+		redisCache *taggedCache
 	}
 )
 
-func NewAuthorizationRepository(db *gorm.DB, redisClient *redis.Client) AuthorizationRepository {
-	c := cache.New(&cache.Options{
-		Redis:      redisClient,
-		LocalCache: cache.NewTinyLFU(1000, time.Minute),
-	})
-	return &authorizationRepository{db: db, redisCache: c}
+func NewAuthorizationRepository(db *gorm.DB, bus *InvalidationBus) AuthorizationRepository {
+	return &authorizationRepository{db: db, redisCache: newTaggedCache(bus)}
 }
 
 func (r *authorizationRepository) Create(auth *entities.Authorization) error {
@@ -60,13 +56,28 @@ func (r *authorizationRepository) GetById(id uuid.UUID) (*entities.Authorization
 	return &auth, nil
 }
 
-func (r *authorizationRepository) GetAll() ([]entities.Authorization, error) {
+func (r *authorizationRepository) GetAll(pq helpers.PageQuery) ([]entities.Authorization, int64, string, error) {
 	var auths []entities.Authorization
-	if err := r.db.Find(&auths).Error; err != nil {
-		return nil, err
+	var total int64
+	var cursor string
+
+	query := r.db.Model(&entities.Authorization{})
+
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	if err := query.Scopes(pageScope("authorizations", pq)).Find(&auths).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	if pq.UseCursor {
+		auths, cursor = nextCursor(auths, pq.Size, func(auth entities.Authorization) uuid.UUID { return auth.ID })
 	}
 
-	return auths, nil
+	return auths, total, cursor, nil
 }
 
 func (r *authorizationRepository) Update(auth *entities.Authorization) error {
@@ -97,6 +108,10 @@ func (r *authorizationRepository) GetUserByEmail(email string) (*entities.User,
 	if err := r.db.Preload("Role.Features").Where("email=?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
+
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
@@ -105,54 +120,59 @@ func (r *authorizationRepository) GetUserByPhoneNumber(phone string) (*entities.
 	if err := r.db.Preload("Role.Features").Where("phone_number=?", phone).First(&user).Error; err != nil {
 		return nil, err
 	}
+
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
 func (r *authorizationRepository) GetUserByIdModify(id uuid.UUID) (*entities.ResUserDTO, error) {
 	var user entities.User
-	if err := r.db.Model(&entities.User{}).Preload("Role.Features").Where("id=?", id).First(&user).Error; err != nil {
+	if err := r.db.Model(&entities.User{}).Preload("Role").Where("id=?", id).First(&user).Error; err != nil {
 		return nil, err
 	}
 
-	var mergedPermissions []entities.FeatureDTODetails
-	for _, Feature := range user.Role.Features {
-		var roleFeature entities.RoleFeature
-		if err := r.db.Where("role_id=? AND feature_id=?", user.Role.ID, Feature.ID).First(&roleFeature).Error; err != nil {
-			return nil, err
-		}
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
 
-		mergedPermissions = append(mergedPermissions, entities.FeatureDTODetails{
-			ID:           Feature.ID,
-			Name:         Feature.Name,
-			ParentMenuId: Feature.ParentMenuId,
-			MenuIcon:     returnNull(Feature.MenuIcon),
-			MenuNameTh:   Feature.MenuNameTh,
-			MenuNameEn:   Feature.MenuNameEn,
-			MenuSlug:     Feature.MenuSlug,
-			MenuSeqNo:    Feature.MenuSeqNo,
-			IsActive:     Feature.IsActive,
-			IsAdd:        roleFeature.IsAdd,
-			IsView:       roleFeature.IsView,
-			IsEdit:       roleFeature.IsEdit,
-			IsDelete:     roleFeature.IsDelete,
-		})
+	roles, err := userRoles(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
+	ends, err := roleValidUntil(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	mergedPermissions, overrides, err := permissionsOfUser(context.Background(), r.db, r.redisCache, id, roles)
+	if err != nil {
+		return nil, err
 	}
 
 	userDTO := entities.ResUserDTO{
-		UserID:            user.ID,
-		Email:             user.Email,
-		FirstName:         user.FirstName,
-		LastName:          user.LastName,
-		PhoneNumber:       user.PhoneNumber,
-		Avatar:            returnNull(user.Avatar),
-		RoleId:            *user.RoleId,
-		RoleName:          user.Role.Name,
-		RoleLevel:         user.Role.Level,
-		TwoFactorEnabled:  *user.TwoFactorEnabled,
-		TwoFactorVerified: *user.TwoFactorVerified,
-		TwoFactorToken:    returnNull(user.TwoFactorToken),
-		TwoFactorAuthUrl:  returnNull(user.TwoFactorAuthUrl),
-		Features:          mergedPermissions,
+		UserID:              user.ID,
+		Email:               user.Email,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		PhoneNumber:         user.PhoneNumber,
+		Avatar:              returnNull(user.Avatar),
+		RoleId:              user.Role.ID,
+		RoleName:            user.Role.Name,
+		RoleLevel:           user.RoleLevel(),
+		RoleValidUntil:      user.RoleValidUntil,
+		FallbackRoleId:      user.FallbackRoleId,
+		OrgUnitId:           user.OrgUnitId,
+		Roles:               rolesInUser(roles, ends),
+		TwoFactorEnabled:    *user.TwoFactorEnabled,
+		TwoFactorVerified:   *user.TwoFactorVerified,
+		TwoFactorToken:      returnNull(user.TwoFactorToken),
+		TwoFactorAuthUrl:    returnNull(user.TwoFactorAuthUrl),
+		Features:            mergedPermissions,
+		PermissionOverrides: overrides,
 	}
 
 	return &userDTO, nil
@@ -164,6 +184,10 @@ func (r *authorizationRepository) GetUserById(id uuid.UUID) (*entities.User, err
 		return nil, err
 	}
 
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
 
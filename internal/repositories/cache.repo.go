@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"work01/internal/helpers"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	userCacheTag    = "user"
+	roleCacheTag    = "role"
+	featureCacheTag = "feature"
+
+	cacheTTL = time.Minute * 10
+
+	// localCacheTTL is how long the in-memory layer keeps an entry.
+	localCacheTTL = time.Minute
+)
+
+// taggedCache is the two-level cache shared by the repositories. Every entry
+// written with SetTagged is recorded in one Redis set per tag, so a write can
+// evict everything derived from an entity with Invalidate instead of trying
+// to rebuild individual keys. Every taggedCache of a process shares the
+// in-memory layer of its bus, and evictions are broadcast on the bus so the
+// in-memory layer of every other instance drops them too.
+type taggedCache struct {
+	*cache.Cache
+	redisClient *redis.Client
+	bus         *InvalidationBus
+}
+
+// cachedPage is what list queries store, so a cache hit can answer with the
+// same total and cursor as the query that produced it.
+type cachedPage[T any] struct {
+	Items  []T
+	Total  int64
+	Cursor string
+}
+
+func newTaggedCache(bus *InvalidationBus) *taggedCache {
+	c := cache.New(&cache.Options{
+		Redis:      bus.redisClient,
+		LocalCache: bus.local,
+	})
+	return &taggedCache{Cache: c, redisClient: bus.redisClient, bus: bus}
+}
+
+func tagSetKey(tag string) string {
+	return fmt.Sprintf("cache_tag:%s", tag)
+}
+
+// listCacheKey derives a key from the normalized query so that every page,
+// size, sort and filter combination is cached separately.
+func listCacheKey(tag string, pq helpers.PageQuery, filters map[string]string) string {
+	if pq.UseCursor {
+		pq.Page = 0
+	}
+
+	b, _ := json.Marshal(struct {
+		Page    helpers.PageQuery
+		Filters map[string]string
+	}{pq, filters})
+
+	sum := sha1.Sum(b)
+	return fmt.Sprintf("%s:list:%s", tag, hex.EncodeToString(sum[:]))
+}
+
+// Get counts every lookup as a hit or miss of the key family, the part of
+// the key before the first colon.
+func (c *taggedCache) Get(ctx context.Context, key string, value interface{}) error {
+	err := c.Cache.Get(ctx, key, value)
+	helpers.RecordCacheLookup(cacheFamily(key), err == nil)
+	return err
+}
+
+func cacheFamily(key string) string {
+	family, _, _ := strings.Cut(key, ":")
+	return family
+}
+
+func (c *taggedCache) SetTagged(ctx context.Context, key string, value interface{}, tags ...string) error {
+	return c.setTagged(ctx, key, value, cacheTTL, false, tags...)
+}
+
+// SetTaggedUntil is SetTagged for entries that go stale at until without any
+// write to evict them, such as a role window opening or closing. The entry
+// expires by then, and is not cached at all when until is too close. A nil
+// until behaves like SetTagged.
+func (c *taggedCache) SetTaggedUntil(ctx context.Context, key string, value interface{}, until *time.Time, tags ...string) error {
+	if until == nil {
+		return c.SetTagged(ctx, key, value, tags...)
+	}
+
+	ttl := time.Until(*until)
+	if ttl >= cacheTTL {
+		return c.SetTagged(ctx, key, value, tags...)
+	}
+	if ttl < time.Second {
+		return nil
+	}
+
+	// the in-memory layer has its own fixed TTL, so a shorter entry only goes
+	// to Redis
+	return c.setTagged(ctx, key, value, ttl, ttl < localCacheTTL, tags...)
+}
+
+func (c *taggedCache) setTagged(ctx context.Context, key string, value interface{}, ttl time.Duration, skipLocal bool, tags ...string) error {
+	if err := c.Set(&cache.Item{
+		Ctx:            ctx,
+		Key:            key,
+		Value:          value,
+		TTL:            ttl,
+		SkipLocalCache: skipLocal,
+	}); err != nil {
+		return err
+	}
+
+	pipe := c.redisClient.Pipeline()
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagSetKey(tag), key)
+		pipe.Expire(ctx, tagSetKey(tag), cacheTTL)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Invalidate evicts every entry recorded under tags. Members are popped rather
+// than read so that keys tagged while the eviction runs are not forgotten.
+func (c *taggedCache) Invalidate(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		for {
+			keys, err := c.redisClient.SPopN(ctx, tagSetKey(tag), 100).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) == 0 {
+				break
+			}
+
+			for _, key := range keys {
+				if err := c.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+					return err
+				}
+			}
+
+			if err := c.bus.Publish(ctx, keys); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -2,16 +2,20 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 
-	"github.com/go-redis/cache/v9"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// featureTreeLockKey is the advisory lock that serializes moves, deletes,
+// restores and purges in the menu tree.
+const featureTreeLockKey = 460031
+
 type (
 	FeatureRepository interface {
 		Create(user *entities.Feature) error
@@ -19,29 +23,59 @@ type (
 		GetMenuIconByFeatureId(id uuid.UUID) (*entities.ResMenuIcon, error)
 		RefForFeature() ([]entities.RefFeatureDTO, error)
 		GetAllDefault() ([]entities.Feature, error)
-		GetAllRoleFeatures(ctx context.Context) ([]entities.FeatureDTO, error)
+		GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) ([]entities.FeatureDTO, int64, string, error)
 		Update(ctx context.Context, feature *entities.Feature) error
-		Delete(id uuid.UUID) error
+		Delete(ctx context.Context, id uuid.UUID, deleteBy uuid.UUID, cascade bool) error
+		Restore(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+		Purge(ctx context.Context, id uuid.UUID) ([]entities.Feature, error)
+		GetDeletedById(id uuid.UUID) (*entities.Feature, error)
+		GetDescendants(id uuid.UUID) ([]entities.Feature, error)
+		GetRolesUsingFeatures(ids []uuid.UUID) ([]entities.Role, error)
+		CountUsersWithRoles(roleIds []uuid.UUID) (int64, error)
+		GetDetailById(id uuid.UUID) (*entities.Feature, error)
+		GetBySlug(ctx context.Context, slug string) (*entities.Feature, error)
+		MenuSlugIsAlreadyExits(slug string) (bool, error)
+		MenuSlugIsAlreadyExitsUpdate(id uuid.UUID, slug string) (bool, error)
+		GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error)
+		GetSubtreeDepth(id uuid.UUID) (int, error)
+		CheckParent(featureId uuid.UUID, parentId uuid.UUID, maxDepth int) error
+		NextSeqNo(parentId *uuid.UUID) (int32, error)
+		Move(ctx context.Context, id uuid.UUID, parentId *uuid.UUID, position int, maxDepth int) error
+		Reorder(ctx context.Context, parentId *uuid.UUID, ids []uuid.UUID) error
 	}
 
 	featureRepository struct {
 		db         *gorm.DB
-		redisCache *cache.Cache
+		redisCache *taggedCache
 	}
 )
 
-func NewFeatureRepository(db *gorm.DB, redisClient *redis.Client) FeatureRepository {
-	c := cache.New(&cache.Options{
-		Redis:      redisClient,
-		LocalCache: cache.NewTinyLFU(1000, time.Minute),
-	})
-	return &featureRepository{db: db, redisCache: c}
+func NewFeatureRepository(db *gorm.DB, bus *InvalidationBus) FeatureRepository {
+	return &featureRepository{db: db, redisCache: newTaggedCache(bus)}
 }
 
+// Create saves feature with its declared actions, the standard ones when
+// none are given.
 func (r *featureRepository) Create(feature *entities.Feature) error {
-	if err := r.db.Create(&feature).Error; err != nil {
+	if feature.Actions == nil {
+		feature.Actions = entities.StandardActions
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&feature).Error; err != nil {
+			return err
+		}
+
+		return saveFeatureActions(tx, feature.ID, feature.Actions)
+	})
+	if err != nil {
 		return err
 	}
+
+	if err := r.redisCache.Invalidate(context.Background(), featureCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -67,7 +101,7 @@ func (r *featureRepository) GetById(ctx context.Context, id uuid.UUID) (*entitie
 		return &featureRole, nil
 	}
 
-	if err := r.db.Model(&entities.RoleFeature{}).Preload("Feature").Joins("LEFT JOIN features ON role_features.feature_id = features.id").Where("role_features.feature_id=?", id).First(&obj).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&entities.RoleFeature{}).Preload("Feature").Joins("LEFT JOIN features ON role_features.feature_id = features.id").Where("role_features.feature_id=?", id).First(&obj).Error; err != nil {
 		return nil, err
 	}
 
@@ -80,12 +114,7 @@ func (r *featureRepository) GetById(ctx context.Context, id uuid.UUID) (*entitie
 		IsDelete:     obj.IsDelete,
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey,
-		Value: featureRole,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	if err := r.redisCache.SetTagged(ctx, cacheKey, featureRole, featureCacheTag, roleCacheTag); err != nil {
 		return nil, err
 	}
 
@@ -123,40 +152,64 @@ func (r *featureRepository) GetAllDefault() ([]entities.Feature, error) {
 		return nil, err
 	}
 
+	if err := loadFeatureActions(r.db, features); err != nil {
+		return nil, err
+	}
+
 	return features, nil
 }
 
+// Update saves feature. When Actions is set it replaces the declared actions
+// and revokes grants of the actions that were dropped.
 func (r *featureRepository) Update(ctx context.Context, feature *entities.Feature) error {
-	if err := r.db.Where("id=?", feature.ID).Updates(&feature).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", feature.ID).Updates(&feature).Error; err != nil {
+			return err
+		}
+
+		if feature.Actions == nil {
+			return nil
+		}
+
+		return saveFeatureActions(tx, feature.ID, feature.Actions)
+	})
+	if err != nil {
 		return err
 	}
 
-	cacheKey1 := fmt.Sprintf("feature:%s", feature.ID)
-	if err := r.redisCache.Delete(ctx, cacheKey1); err != nil {
-		return nil
+	if err := r.redisCache.Invalidate(ctx, featureCacheTag, roleCacheTag); err != nil {
+		return err
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey1,
-		Value: feature,
-		TTL:   time.Minute * 10,
-	}); err != nil {
-		return err
+	return nil
+}
+
+func (r *featureRepository) GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) ([]entities.FeatureDTO, int64, string, error) {
+	var featureRole []entities.FeatureDTO
+	var roleFeatures []entities.RoleFeature
+	var total int64
+	var cursor string
+	var cached cachedPage[entities.FeatureDTO]
+
+	cacheKey := listCacheKey(featureCacheTag, pq, nil)
+	if err := r.redisCache.Get(ctx, cacheKey, &cached); err == nil {
+		return cached.Items, cached.Total, cached.Cursor, nil
 	}
 
-	// //query again
+	query := r.db.WithContext(ctx).Model(&entities.RoleFeature{}).Joins("JOIN features ON role_features.feature_id = features.id AND features.deleted_at IS NULL")
 
-	cacheKey2 := "role_feature_list"
-	if err := r.redisCache.Delete(ctx, cacheKey2); err != nil {
-		return err
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 	}
 
-	var roleFeatures []entities.RoleFeature
-	var featureRole []entities.FeatureDTO
+	if err := query.Preload("Feature").Scopes(pageScope("role_features", pq)).Find(&roleFeatures).Error; err != nil {
+		return nil, 0, "", err
+	}
 
-	if err := r.db.Model(&entities.RoleFeature{}).Preload("Feature").Joins("LEFT JOIN features ON role_features.feature_id = features.id").Find(&roleFeatures).Error; err != nil {
-		return err
+	if pq.UseCursor {
+		roleFeatures, cursor = nextCursor(roleFeatures, pq.Size, func(rf entities.RoleFeature) uuid.UUID { return rf.ID })
 	}
 
 	for _, obj := range roleFeatures {
@@ -170,58 +223,562 @@ func (r *featureRepository) Update(ctx context.Context, feature *entities.Featur
 		})
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey2,
-		Value: featureRole,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	cached = cachedPage[entities.FeatureDTO]{Items: featureRole, Total: total, Cursor: cursor}
+	if err := r.redisCache.SetTagged(ctx, cacheKey, cached, featureCacheTag, roleCacheTag); err != nil {
+		return nil, 0, "", err
+	}
+
+	return featureRole, total, cursor, nil
+}
+
+// Delete soft-deletes id and, with cascade, every feature below it in one
+// transaction. It refuses when roles or child menus depend on the subtree and
+// cascade is not set. The subtree is read under the tree lock Move takes, so
+// no feature is moved below id between the check and the delete. The
+// role_features rows and menu icons are kept so Restore brings them back;
+// queries skip them meanwhile because the feature itself is hidden.
+func (r *featureRepository) Delete(ctx context.Context, id uuid.UUID, deleteBy uuid.UUID, cascade bool) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", featureTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).First(&entities.Feature{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("feature not found")
+			}
+			return err
+		}
+
+		below, err := descendants(tx, id)
+		if err != nil {
+			return err
+		}
+
+		ids := []uuid.UUID{id}
+		for _, d := range below {
+			ids = append(ids, d.ID)
+		}
+
+		roles, err := rolesUsingFeatures(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		if !cascade && (len(below) > 0 || len(roles) > 0) {
+			return fmt.Errorf("feature is used by %d role(s) and has %d child menu(s), delete with cascade to remove them too", len(roles), len(below))
+		}
+
+		if err := tx.Model(&entities.Feature{}).Where("id IN ?", ids).Update("deleted_by", deleteBy).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&entities.Feature{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+
+		return bumpRoleVersionsByFeatures(tx, ids)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, featureCacheTag, roleCacheTag); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (r *featureRepository) GetAllRoleFeatures(ctx context.Context) ([]entities.FeatureDTO, error) {
-	var featureRole []entities.FeatureDTO
-	var roleFeatures []entities.RoleFeature
+// Restore undeletes id together with the descendants removed by the same
+// cascade, identified by sharing its deleted_at timestamp. It refuses while
+// the parent is deleted or another feature has taken one of the slugs.
+func (r *featureRepository) Restore(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", featureTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		var feature entities.Feature
+		if err := tx.Unscoped().Where("id=? AND deleted_at IS NOT NULL", id).First(&feature).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if ids, err = deletedSubtreeIds(tx, feature); err != nil {
+			return err
+		}
+
+		// the share lock keeps the parent from being deleted until we commit
+		if feature.ParentMenuId != nil {
+			if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id=?", *feature.ParentMenuId).First(&entities.Feature{}).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("parent feature is deleted, restore it first")
+				}
+				return err
+			}
+		}
+
+		var taken []string
+		if err := tx.Model(&entities.Feature{}).
+			Where("id NOT IN ? AND menu_slug IN (?)", ids, tx.Unscoped().Model(&entities.Feature{}).Select("menu_slug").Where("id IN ?", ids)).
+			Pluck("menu_slug", &taken).Error; err != nil {
+			return err
+		}
+		if len(taken) > 0 {
+			return fmt.Errorf("the menu slug %s is now used by another feature", taken[0])
+		}
+
+		if err := tx.Unscoped().Model(&entities.Feature{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		return bumpRoleVersionsByFeatures(tx, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, featureCacheTag, roleCacheTag); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
 
-	cacheKey1 := "role_feature_list"
-	if err := r.redisCache.Get(ctx, cacheKey1, &featureRole); err == nil {
-		return featureRole, nil
+// Purge removes deleted feature id for good, together with the descendants
+// removed by the same cascade, their grants, declared actions and overrides.
+// It returns the purged features so their menu icons can be removed too.
+func (r *featureRepository) Purge(ctx context.Context, id uuid.UUID) ([]entities.Feature, error) {
+	var features []entities.Feature
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", featureTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		var feature entities.Feature
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=? AND deleted_at IS NOT NULL", id).First(&feature).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("deleted feature not found")
+			}
+			return err
+		}
+
+		ids, err := deletedSubtreeIds(tx, feature)
+		if err != nil {
+			return err
+		}
+
+		// a child deleted on its own would be left without its parent
+		var children int64
+		if err := tx.Unscoped().Model(&entities.Feature{}).Where("parent_menu_id IN ? AND id NOT IN ?", ids, ids).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return fmt.Errorf("feature has %d child menu(s) that were deleted separately, purge them first", children)
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", ids).Find(&features).Error; err != nil {
+			return err
+		}
+
+		roleFeatureIds := tx.Model(&entities.RoleFeature{}).Select("id").Where("feature_id IN ?", ids)
+		if err := tx.Where("role_feature_id IN (?)", roleFeatureIds).Delete(&entities.RoleFeatureAction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feature_id IN ?", ids).Delete(&entities.RoleFeature{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feature_id IN ?", ids).Delete(&entities.FeatureAction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feature_id IN ?", ids).Delete(&entities.PermissionOverride{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&entities.Feature{}, "id IN ?", ids).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := r.db.Model(&entities.RoleFeature{}).Preload("Feature").Joins("LEFT JOIN features ON role_features.feature_id = features.id").Find(&roleFeatures).Error; err != nil {
+	if err := r.redisCache.Invalidate(ctx, featureCacheTag, roleCacheTag); err != nil {
 		return nil, err
 	}
 
-	for _, obj := range roleFeatures {
-		featureRole = append(featureRole, entities.FeatureDTO{
-			FeatureDTOID: obj.Feature.ID,
-			FeatureName:  obj.Feature.Name,
-			IsView:       obj.IsView,
-			IsAdd:        obj.IsAdd,
-			IsEdit:       obj.IsEdit,
-			IsDelete:     obj.IsDelete,
-		})
+	return features, nil
+}
+
+// deletedSubtreeIds lists feature and the descendants deleted by the same
+// cascade, identified by sharing its deleted_at timestamp.
+func deletedSubtreeIds(db *gorm.DB, feature entities.Feature) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := db.Raw(`WITH RECURSIVE subtree AS (
+		SELECT id, 1 AS depth FROM features WHERE id = ?
+		UNION ALL
+		SELECT f.id, s.depth + 1 FROM features f JOIN subtree s ON f.parent_menu_id = s.id WHERE f.deleted_at = ? AND s.depth < 100
+	) SELECT id FROM subtree`, feature.ID, feature.DeletedAt.Time).Scan(&ids).Error; err != nil {
+		return nil, err
 	}
 
-	cacheKey2 := "role_feature_list"
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey2,
-		Value: featureRole,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	return ids, nil
+}
+
+func (r *featureRepository) GetDeletedById(id uuid.UUID) (*entities.Feature, error) {
+	var feature entities.Feature
+	if err := r.db.Unscoped().Where("id=? AND deleted_at IS NOT NULL", id).First(&feature).Error; err != nil {
 		return nil, err
 	}
 
-	return featureRole, nil
+	return &feature, nil
+}
+
+// GetDescendants lists every live feature below id, excluding id itself.
+func (r *featureRepository) GetDescendants(id uuid.UUID) ([]entities.Feature, error) {
+	return descendants(r.db, id)
 }
 
-func (r *featureRepository) Delete(id uuid.UUID) error {
-	if err := r.db.Delete(&entities.Feature{}, id).Error; err != nil {
+func descendants(db *gorm.DB, id uuid.UUID) ([]entities.Feature, error) {
+	var features []entities.Feature
+	if err := db.Raw(`WITH RECURSIVE subtree AS (
+		SELECT id, 1 AS depth FROM features WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT f.id, s.depth + 1 FROM features f JOIN subtree s ON f.parent_menu_id = s.id WHERE f.deleted_at IS NULL AND s.depth < 100
+	) SELECT features.* FROM features JOIN subtree ON subtree.id = features.id WHERE subtree.depth > 1 ORDER BY subtree.depth, features.menu_seq_no`, id).Scan(&features).Error; err != nil {
+		return nil, err
+	}
+
+	return features, nil
+}
+
+func (r *featureRepository) GetRolesUsingFeatures(ids []uuid.UUID) ([]entities.Role, error) {
+	return rolesUsingFeatures(r.db, ids)
+}
+
+func rolesUsingFeatures(db *gorm.DB, ids []uuid.UUID) ([]entities.Role, error) {
+	var roles []entities.Role
+	if err := db.Where("id IN (?)", db.Model(&entities.RoleFeature{}).Select("role_id").Where("feature_id IN ?", ids)).Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *featureRepository) CountUsersWithRoles(roleIds []uuid.UUID) (int64, error) {
+	var total int64
+	if len(roleIds) == 0 {
+		return 0, nil
+	}
+
+	if err := r.db.Table(roleAssignments).Joins("JOIN users ON users.id = assignments.user_id AND users.deleted_at IS NULL").Where("assignments.role_id IN ?", roleIds).Distinct("assignments.user_id").Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (r *featureRepository) GetDetailById(id uuid.UUID) (*entities.Feature, error) {
+	var feature entities.Feature
+	if err := r.db.Where("id=?", id).First(&feature).Error; err != nil {
+		return nil, err
+	}
+
+	features := []entities.Feature{feature}
+	if err := loadFeatureActions(r.db, features); err != nil {
+		return nil, err
+	}
+
+	return &features[0], nil
+}
+
+func (r *featureRepository) GetBySlug(ctx context.Context, slug string) (*entities.Feature, error) {
+	var feature entities.Feature
+	cacheKey := fmt.Sprintf("feature:slug:%s", slug)
+
+	if err := r.redisCache.Get(ctx, cacheKey, &feature); err == nil {
+		return &feature, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("menu_slug=?", slug).First(&feature).Error; err != nil {
+		return nil, err
+	}
+
+	features := []entities.Feature{feature}
+	if err := loadFeatureActions(r.db.WithContext(ctx), features); err != nil {
+		return nil, err
+	}
+	feature = features[0]
+
+	if err := r.redisCache.SetTagged(ctx, cacheKey, feature, featureCacheTag); err != nil {
+		return nil, err
+	}
+
+	return &feature, nil
+}
+
+func (r *featureRepository) MenuSlugIsAlreadyExits(slug string) (bool, error) {
+	var feature entities.Feature
+	if err := r.db.Where("menu_slug=?", slug).First(&feature).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *featureRepository) MenuSlugIsAlreadyExitsUpdate(id uuid.UUID, slug string) (bool, error) {
+	var feature entities.Feature
+	if err := r.db.Where("menu_slug=? AND id != ?", slug, id).First(&feature).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetAncestorIds returns id followed by each of its parents up to the root,
+// or nothing when id does not exist.
+func (r *featureRepository) GetAncestorIds(id uuid.UUID) ([]uuid.UUID, error) {
+	return ancestorIds(r.db, id)
+}
+
+// GetSubtreeDepth counts the levels of the subtree rooted at id, 1 for a
+// leaf and 0 when id does not exist.
+func (r *featureRepository) GetSubtreeDepth(id uuid.UUID) (int, error) {
+	return subtreeDepth(r.db, id)
+}
+
+// CheckParent checks that parentId exists, is not featureId or one of its
+// descendants, and that hanging the subtree of featureId below it stays
+// within maxDepth levels.
+func (r *featureRepository) CheckParent(featureId uuid.UUID, parentId uuid.UUID, maxDepth int) error {
+	return checkParent(r.db, featureId, parentId, maxDepth)
+}
+
+func ancestorIds(db *gorm.DB, id uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := db.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_menu_id, 1 AS depth FROM features WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT f.id, f.parent_menu_id, a.depth + 1 FROM features f JOIN ancestors a ON f.id = a.parent_menu_id WHERE f.deleted_at IS NULL AND a.depth < 100
+	) SELECT id FROM ancestors ORDER BY depth`, id).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func subtreeDepth(db *gorm.DB, id uuid.UUID) (int, error) {
+	var depth int
+	if err := db.Raw(`WITH RECURSIVE subtree AS (
+		SELECT id, 1 AS depth FROM features WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT f.id, s.depth + 1 FROM features f JOIN subtree s ON f.parent_menu_id = s.id WHERE f.deleted_at IS NULL AND s.depth < 100
+	) SELECT COALESCE(MAX(depth), 0) FROM subtree`, id).Scan(&depth).Error; err != nil {
+		return 0, err
+	}
+
+	return depth, nil
+}
+
+func checkParent(db *gorm.DB, featureId uuid.UUID, parentId uuid.UUID, maxDepth int) error {
+	if featureId == parentId {
+		return fmt.Errorf("a feature can not be its own parent")
+	}
+
+	ancestors, err := ancestorIds(db, parentId)
+	if err != nil {
 		return err
 	}
+
+	if len(ancestors) == 0 {
+		return fmt.Errorf("parent feature not found")
+	}
+
+	for _, ancestor := range ancestors {
+		if ancestor == featureId {
+			return fmt.Errorf("can not move a feature under one of its own children")
+		}
+	}
+
+	depth, err := subtreeDepth(db, featureId)
+	if err != nil {
+		return err
+	}
+
+	if depth == 0 {
+		depth = 1
+	}
+
+	if len(ancestors)+depth > maxDepth {
+		return fmt.Errorf("the menu can not be deeper than %d levels", maxDepth)
+	}
+
+	return nil
+}
+
+func (r *featureRepository) NextSeqNo(parentId *uuid.UUID) (int32, error) {
+	var seqNo int32
+	if err := siblingsQuery(r.db, parentId).Select("COALESCE(MAX(menu_seq_no), 0) + 1").Scan(&seqNo).Error; err != nil {
+		return 0, err
+	}
+
+	return seqNo, nil
+}
+
+// Move re-parents a feature at a 1-based position among its new siblings
+// (0 appends) and renumbers both the old and the new sibling lists in one
+// transaction. Moves take a lock on the whole tree and check the new parent
+// under it, so two concurrent moves can not build a cycle or exceed maxDepth
+// between them.
+func (r *featureRepository) Move(ctx context.Context, id uuid.UUID, parentId *uuid.UUID, position int, maxDepth int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", featureTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		var feature entities.Feature
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).First(&feature).Error; err != nil {
+			return err
+		}
+
+		if parentId != nil {
+			if err := checkParent(tx, id, *parentId, maxDepth); err != nil {
+				return err
+			}
+		}
+
+		oldSiblings, err := siblingIds(tx, feature.ParentMenuId, id)
+		if err != nil {
+			return err
+		}
+
+		sameParent := sameParentId(feature.ParentMenuId, parentId)
+
+		newSiblings := oldSiblings
+		if !sameParent {
+			newSiblings, err = siblingIds(tx, parentId, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		if position < 1 || position > len(newSiblings)+1 {
+			position = len(newSiblings) + 1
+		}
+
+		ordered := make([]uuid.UUID, 0, len(newSiblings)+1)
+		ordered = append(ordered, newSiblings[:position-1]...)
+		ordered = append(ordered, id)
+		ordered = append(ordered, newSiblings[position-1:]...)
+
+		if err := tx.Model(&entities.Feature{}).Where("id=?", id).Update("parent_menu_id", parentId).Error; err != nil {
+			return err
+		}
+
+		if !sameParent {
+			if err := renumber(tx, feature.ParentMenuId, oldSiblings); err != nil {
+				return err
+			}
+		}
+
+		return renumber(tx, parentId, ordered)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.redisCache.Invalidate(ctx, featureCacheTag)
+}
+
+// Reorder assigns sequence numbers 1..n following ids, which must list every
+// child of parentId exactly once. The children are read under the tree lock
+// Move takes, so none can be moved in or out between the check and the
+// update.
+func (r *featureRepository) Reorder(ctx context.Context, parentId *uuid.UUID, ids []uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", featureTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		children, err := siblingIds(tx, parentId, uuid.Nil)
+		if err != nil {
+			return err
+		}
+
+		if len(ids) != len(children) {
+			return fmt.Errorf("featureIds must list every child of the parent exactly once")
+		}
+
+		childIds := make(map[uuid.UUID]bool, len(children))
+		for _, id := range children {
+			childIds[id] = true
+		}
+
+		for _, id := range ids {
+			if !childIds[id] {
+				return fmt.Errorf("featureIds must list every child of the parent exactly once")
+			}
+			delete(childIds, id)
+		}
+
+		return renumber(tx, parentId, ids)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.redisCache.Invalidate(ctx, featureCacheTag)
+}
+
+func siblingsQuery(db *gorm.DB, parentId *uuid.UUID) *gorm.DB {
+	query := db.Model(&entities.Feature{})
+	if parentId == nil {
+		return query.Where("parent_menu_id IS NULL")
+	}
+
+	return query.Where("parent_menu_id = ?", *parentId)
+}
+
+func siblingIds(db *gorm.DB, parentId *uuid.UUID, exclude uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := siblingsQuery(db, parentId).Where("id <> ?", exclude).Order("menu_seq_no, name").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// renumber fails when one of ids is no longer a child of parentId, which
+// rolls the caller's transaction back.
+func renumber(db *gorm.DB, parentId *uuid.UUID, ids []uuid.UUID) error {
+	for i, id := range ids {
+		result := siblingsQuery(db, parentId).Where("id=?", id).Update("menu_seq_no", i+1)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("feature %s is not a child of the parent", id)
+		}
+	}
+
 	return nil
 }
+
+func sameParentId(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+	"work01/internal/helpers"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const invalidationChannel = "cache_invalidation"
+
+type invalidationMessage struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+	SentAt int64    `json:"sentAt"`
+}
+
+// InvalidationBus owns the in-memory cache layer of the process and keeps it
+// consistent with writes made by every other instance. Build one per process,
+// pass it to every repository and Close it on shutdown.
+type InvalidationBus struct {
+	id          string
+	redisClient *redis.Client
+	local       cache.LocalCache
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+func NewInvalidationBus(redisClient *redis.Client) *InvalidationBus {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &InvalidationBus{
+		id:          uuid.NewString(),
+		redisClient: redisClient,
+		local:       cache.NewTinyLFU(1000, localCacheTTL),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+
+	go b.listen(ctx)
+
+	return b
+}
+
+// Close stops listening for evictions from other instances and waits for the
+// subscription to end.
+func (b *InvalidationBus) Close() error {
+	b.cancel()
+	<-b.done
+
+	return nil
+}
+
+func (b *InvalidationBus) Publish(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(invalidationMessage{
+		Source: b.id,
+		Keys:   keys,
+		SentAt: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := b.redisClient.Publish(ctx, invalidationChannel, payload).Err(); err != nil {
+		return err
+	}
+
+	helpers.RecordInvalidationPublished(len(keys))
+
+	return nil
+}
+
+func (b *InvalidationBus) listen(ctx context.Context) {
+	defer close(b.done)
+
+	sub := b.redisClient.Subscribe(ctx, invalidationChannel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		var msg *redis.Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-messages:
+			if msg == nil {
+				return
+			}
+		}
+
+		var m invalidationMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+			log.Printf("invalid cache invalidation message: %v", err)
+			continue
+		}
+
+		// this process already dropped its own keys from the shared local
+		// cache when it deleted them
+		if m.Source == b.id {
+			continue
+		}
+
+		for _, key := range m.Keys {
+			b.local.Del(key)
+		}
+
+		helpers.ObserveInvalidationReceived(len(m.Keys), time.Since(time.Unix(0, m.SentAt)))
+	}
+}
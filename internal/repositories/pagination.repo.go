@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"work01/internal/helpers"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// pageScope orders by the requested column with the table's id as tie-breaker
+// and applies either offset or keyset pagination. In keyset mode one extra
+// row is fetched so nextCursor can tell whether another page exists. NULLs
+// sort last in both directions, and the keyset predicate handles them
+// explicitly because a row comparison with NULL is never true. A cursor whose
+// row was deleted fails the query with helpers.ErrStaleCursor.
+func pageScope(table string, pq helpers.PageQuery) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		sortCol := fmt.Sprintf("%s.%s", table, pq.Sort)
+		idCol := fmt.Sprintf("%s.id", table)
+
+		direction, compare := "ASC", ">"
+		if pq.Desc() {
+			direction, compare = "DESC", "<"
+		}
+
+		db = db.Order(fmt.Sprintf("%s %s NULLS LAST", sortCol, direction))
+		if pq.Sort != "id" {
+			db = db.Order(fmt.Sprintf("%s %s", idCol, direction))
+		}
+
+		if !pq.UseCursor {
+			return db.Limit(pq.Size).Offset(pq.Offset())
+		}
+
+		if pq.Cursor != uuid.Nil {
+			// the predicate reads the sort value from the cursor row, which
+			// would be NULL for a deleted row and skip to the wrong place
+			var found int64
+			if err := db.Session(&gorm.Session{NewDB: true}).Table(table).Where("id = ?", pq.Cursor).Count(&found).Error; err != nil {
+				db.AddError(err)
+				return db
+			}
+			if found == 0 {
+				db.AddError(helpers.ErrStaleCursor)
+				return db
+			}
+
+			last := fmt.Sprintf("(SELECT %s FROM %s WHERE id = @cursor)", pq.Sort, table)
+			db = db.Where(fmt.Sprintf(
+				"(%[1]s IS NULL AND %[2]s IS NULL AND %[3]s %[4]s @cursor) OR "+
+					"(%[1]s IS NOT NULL AND (%[2]s IS NULL OR %[2]s %[4]s %[1]s OR (%[2]s = %[1]s AND %[3]s %[4]s @cursor)))",
+				last, sortCol, idCol, compare), sql.Named("cursor", pq.Cursor))
+		}
+
+		return db.Limit(pq.Size + 1)
+	}
+}
+
+// nextCursor trims the look-ahead row fetched by pageScope and returns the
+// cursor for the following page, or "" when rows is the last page.
+func nextCursor[T any](rows []T, size int, id func(T) uuid.UUID) ([]T, string) {
+	if len(rows) <= size {
+		return rows, ""
+	}
+
+	rows = rows[:size]
+	return rows, helpers.EncodeCursor(id(rows[len(rows)-1]))
+}
@@ -0,0 +1,421 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"work01/internal/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	PermissionRepository interface {
+		GetRolePermissions(ctx context.Context, roleId uuid.UUID) ([]entities.FeatureDTODetails, error)
+		GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]entities.FeatureDTODetails, error)
+	}
+
+	permissionRepository struct {
+		db         *gorm.DB
+		redisCache *taggedCache
+	}
+
+	effectivePermissionRow struct {
+		ID           uuid.UUID
+		Name         string
+		ParentMenuId *uuid.UUID
+		MenuIcon     string
+		MenuNameTh   string
+		MenuNameEn   string
+		MenuSlug     string
+		MenuSeqNo    int32
+		IsActive     *bool
+		IsAdd        *bool
+		IsView       *bool
+		IsEdit       *bool
+		IsDelete     *bool
+	}
+)
+
+func NewPermissionRepository(db *gorm.DB, bus *InvalidationBus) PermissionRepository {
+	return &permissionRepository{db: db, redisCache: newTaggedCache(bus)}
+}
+
+func (r *permissionRepository) GetRolePermissions(ctx context.Context, roleId uuid.UUID) ([]entities.FeatureDTODetails, error) {
+	var role entities.Role
+	if err := r.db.WithContext(ctx).Select("id", "version").Where("id=?", roleId).First(&role).Error; err != nil {
+		return nil, err
+	}
+
+	return effectivePermissions(ctx, r.db.WithContext(ctx), r.redisCache, role)
+}
+
+func (r *permissionRepository) GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]entities.FeatureDTODetails, error) {
+	roles, err := userRoles(r.db.WithContext(ctx), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, _, err := permissionsOfUser(ctx, r.db.WithContext(ctx), r.redisCache, userId, roles)
+	return permissions, err
+}
+
+// roleAssignments is every (user_id, role_id) pair in effect now: the primary
+// role kept on users, or its fallback outside the primary role's window, plus
+// the additional ones in user_roles within their window. valid_until is when
+// the pair stops applying, NULL for never.
+const roleAssignments = `(SELECT user_id, role_id, valid_until FROM user_roles
+	WHERE (valid_from IS NULL OR valid_from <= now()) AND (valid_until IS NULL OR valid_until > now())
+	UNION SELECT id AS user_id, role_id, role_valid_until AS valid_until FROM users
+	WHERE role_id IS NOT NULL AND deleted_at IS NULL
+	AND (role_valid_from IS NULL OR role_valid_from <= now()) AND (role_valid_until IS NULL OR role_valid_until > now())
+	UNION SELECT id AS user_id, fallback_role_id AS role_id, NULL AS valid_until FROM users
+	WHERE fallback_role_id IS NOT NULL AND deleted_at IS NULL
+	AND NOT ((role_valid_from IS NULL OR role_valid_from <= now()) AND (role_valid_until IS NULL OR role_valid_until > now()))) AS assignments`
+
+// userRoles returns every role of userId, highest level first.
+func userRoles(db *gorm.DB, userId uuid.UUID) ([]entities.Role, error) {
+	var roles []entities.Role
+	if err := db.Where("id IN (?)", db.Table(roleAssignments).Select("role_id").Where("user_id = ?", userId)).Order("level DESC, name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// resolvePrimaryRole replaces the primary role of user with its fallback when
+// the primary role is outside its validity window, so callers never see an
+// expired role even before the expiry job has run.
+func resolvePrimaryRole(db *gorm.DB, user *entities.User) error {
+	if user.RoleActiveAt(time.Now()) {
+		return nil
+	}
+
+	user.RoleId = user.FallbackRoleId
+	user.Role = entities.Role{}
+	if user.FallbackRoleId == nil {
+		return nil
+	}
+
+	return db.Preload("Features").Where("id = ?", *user.FallbackRoleId).Limit(1).Find(&user.Role).Error
+}
+
+// roleValidUntil returns when each role currently held by userId ends, nil
+// for roles held without an end.
+func roleValidUntil(db *gorm.DB, userId uuid.UUID) (map[uuid.UUID]*time.Time, error) {
+	var rows []struct {
+		RoleId     uuid.UUID
+		ValidUntil *time.Time
+	}
+
+	if err := db.Table(roleAssignments).Select("role_id, valid_until").Where("user_id = ?", userId).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ends := make(map[uuid.UUID]*time.Time, len(rows))
+	for _, row := range rows {
+		end, ok := ends[row.RoleId]
+		switch {
+		case !ok:
+			ends[row.RoleId] = row.ValidUntil
+		case end != nil && (row.ValidUntil == nil || row.ValidUntil.After(*end)):
+			ends[row.RoleId] = row.ValidUntil
+		}
+	}
+
+	return ends, nil
+}
+
+// roleBoundaries is every future instant at which a role window opens or
+// closes, the moments roleAssignments changes without any write.
+const roleBoundaries = `(SELECT user_id, valid_from AS at FROM user_roles WHERE valid_from > now()
+	UNION ALL SELECT user_id, valid_until FROM user_roles WHERE valid_until > now()
+	UNION ALL SELECT id, role_valid_from FROM users WHERE deleted_at IS NULL AND role_valid_from > now()
+	UNION ALL SELECT id, role_valid_until FROM users WHERE deleted_at IS NULL AND role_valid_until > now()) AS boundaries`
+
+// nextRoleBoundary returns when the roles of userIds next change by a window
+// opening or closing, or of every user when no id is given. It is nil when
+// no window is pending.
+func nextRoleBoundary(db *gorm.DB, userIds ...uuid.UUID) (*time.Time, error) {
+	var next *time.Time
+
+	query := db.Table(roleBoundaries).Select("MIN(at)")
+	if len(userIds) > 0 {
+		query = query.Where("user_id IN ?", userIds)
+	}
+
+	if err := query.Scan(&next).Error; err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+func rolesInUser(roles []entities.Role, ends map[uuid.UUID]*time.Time) []entities.RoleInUser {
+	res := make([]entities.RoleInUser, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, entities.RoleInUser{RoleId: role.ID, RoleName: role.Name, RoleLevel: role.Level, ValidUntil: ends[role.ID]})
+	}
+
+	return res
+}
+
+// countUsersByRole counts the distinct live users holding each role, whether
+// as their primary role or an additional one.
+func countUsersByRole(db *gorm.DB, roleIds []uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		RoleId uuid.UUID
+		Total  int64
+	}
+
+	counts := make(map[uuid.UUID]int64, len(roleIds))
+	if len(roleIds) == 0 {
+		return counts, nil
+	}
+
+	if err := db.Table(roleAssignments).
+		Select("assignments.role_id, COUNT(DISTINCT assignments.user_id) AS total").
+		Joins("JOIN users ON users.id = assignments.user_id AND users.deleted_at IS NULL").
+		Where("assignments.role_id IN ?", roleIds).
+		Group("assignments.role_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.RoleId] = row.Total
+	}
+
+	return counts, nil
+}
+
+// userPermissions merges the permission sets of roles: an action is granted
+// when any of the roles grants it.
+func userPermissions(ctx context.Context, db *gorm.DB, c *taggedCache, roles []entities.Role) ([]entities.FeatureDTODetails, error) {
+	var merged []entities.FeatureDTODetails
+	index := map[uuid.UUID]int{}
+
+	for _, role := range roles {
+		permissions, err := effectivePermissions(ctx, db, c, role)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range permissions {
+			i, ok := index[p.ID]
+			if !ok {
+				index[p.ID] = len(merged)
+				merged = append(merged, p)
+				continue
+			}
+
+			merged[i].SetActions(unionActions(merged[i].Actions, p.Actions))
+		}
+	}
+
+	if len(roles) > 1 {
+		sortPermissions(merged)
+	}
+
+	return merged, nil
+}
+
+func sortPermissions(permissions []entities.FeatureDTODetails) {
+	sort.SliceStable(permissions, func(i, j int) bool {
+		if permissions[i].MenuSeqNo != permissions[j].MenuSeqNo {
+			return permissions[i].MenuSeqNo < permissions[j].MenuSeqNo
+		}
+		return permissions[i].Name < permissions[j].Name
+	})
+}
+
+// permissionsOfUser is the final permission set of userId: the merged role
+// permissions with the user's overrides applied on top. Overrides are read on
+// every call rather than cached so an expiry takes effect immediately.
+func permissionsOfUser(ctx context.Context, db *gorm.DB, c *taggedCache, userId uuid.UUID, roles []entities.Role) ([]entities.FeatureDTODetails, []entities.ResPermissionOverride, error) {
+	permissions, err := userPermissions(ctx, db, c, roles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrides, err := userOverrides(db, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	permissions, err = applyOverrides(db, permissions, overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return permissions, overrides, nil
+}
+
+// userOverrides lists every override of userId on a live feature, expired
+// ones included so admin screens can show them.
+func userOverrides(db *gorm.DB, userId uuid.UUID) ([]entities.ResPermissionOverride, error) {
+	overrides := []entities.ResPermissionOverride{}
+	if err := db.Model(&entities.PermissionOverride{}).
+		Select("permission_overrides.*, features.name AS feature_name, features.menu_slug").
+		Joins("JOIN features ON features.id = permission_overrides.feature_id AND features.deleted_at IS NULL").
+		Where("permission_overrides.user_id = ?", userId).
+		Order("features.menu_seq_no, features.name, permission_overrides.action").
+		Scan(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range overrides {
+		overrides[i].Expired = overrides[i].ExpiresAt != nil && !overrides[i].ExpiresAt.After(now)
+	}
+
+	return overrides, nil
+}
+
+// applyOverrides returns permissions with the unexpired overrides applied: a
+// grant adds its action and a deny removes it, whatever the roles allow. A
+// grant on a feature none of the roles include adds that feature.
+func applyOverrides(db *gorm.DB, permissions []entities.FeatureDTODetails, overrides []entities.ResPermissionOverride) ([]entities.FeatureDTODetails, error) {
+	if len(overrides) == 0 {
+		return permissions, nil
+	}
+
+	applied := make([]entities.FeatureDTODetails, len(permissions))
+	copy(applied, permissions)
+
+	index := make(map[uuid.UUID]int, len(applied))
+	for i, p := range applied {
+		index[p.ID] = i
+	}
+
+	var missing []uuid.UUID
+	for _, o := range overrides {
+		if _, ok := index[o.FeatureId]; !ok && !o.Expired && o.Effect == entities.OverrideGrant {
+			index[o.FeatureId] = -1
+			missing = append(missing, o.FeatureId)
+		}
+	}
+
+	if len(missing) > 0 {
+		var features []entities.Feature
+		if err := db.Where("id IN ?", missing).Find(&features).Error; err != nil {
+			return nil, err
+		}
+
+		for _, f := range features {
+			permission := entities.FeatureDTODetails{
+				ID:           f.ID,
+				Name:         f.Name,
+				ParentMenuId: f.ParentMenuId,
+				MenuIcon:     returnNull(f.MenuIcon),
+				MenuNameTh:   f.MenuNameTh,
+				MenuNameEn:   f.MenuNameEn,
+				MenuSlug:     f.MenuSlug,
+				MenuSeqNo:    f.MenuSeqNo,
+				IsActive:     f.IsActive,
+			}
+			permission.SetActions([]string{})
+			index[f.ID] = len(applied)
+			applied = append(applied, permission)
+		}
+
+		sortPermissions(applied)
+		for i, p := range applied {
+			index[p.ID] = i
+		}
+	}
+
+	for _, o := range overrides {
+		i, ok := index[o.FeatureId]
+		if o.Expired || !ok || i < 0 {
+			continue
+		}
+
+		actions := []string{}
+		for _, action := range applied[i].Actions {
+			if action != o.Action {
+				actions = append(actions, action)
+			}
+		}
+		if o.Effect == entities.OverrideGrant {
+			actions = append(actions, o.Action)
+		}
+
+		applied[i].SetActions(unionActions(actions))
+		applied[i].Overridden = append(applied[i].Overridden, o.Action)
+	}
+
+	return applied, nil
+}
+
+// effectivePermissions compiles the full permission set of role with a single
+// query. Entries are keyed by role id and version, so bumping the version on
+// any change to the role's matrix makes stale sets unreachable even before
+// the tag invalidation reaches every instance.
+func effectivePermissions(ctx context.Context, db *gorm.DB, c *taggedCache, role entities.Role) ([]entities.FeatureDTODetails, error) {
+	var permissions []entities.FeatureDTODetails
+	var rows []effectivePermissionRow
+
+	if role.ID == uuid.Nil {
+		return permissions, nil
+	}
+
+	cacheKey := fmt.Sprintf("permission:%s:v%d", role.ID, role.Version)
+	if err := c.Get(ctx, cacheKey, &permissions); err == nil {
+		return permissions, nil
+	}
+
+	if err := db.Model(&entities.RoleFeature{}).
+		Select("features.id, features.name, features.parent_menu_id, features.menu_icon, features.menu_name_th, features.menu_name_en, features.menu_slug, features.menu_seq_no, features.is_active, role_features.is_add, role_features.is_view, role_features.is_edit, role_features.is_delete").
+		Joins("JOIN features ON features.id = role_features.feature_id AND features.deleted_at IS NULL").
+		Where("role_features.role_id = ?", role.ID).
+		Order("features.menu_seq_no, features.name").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	granted, err := grantedActions(db, role.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		permission := entities.FeatureDTODetails{
+			ID:           row.ID,
+			Name:         row.Name,
+			ParentMenuId: row.ParentMenuId,
+			MenuIcon:     returnNull(row.MenuIcon),
+			MenuNameTh:   row.MenuNameTh,
+			MenuNameEn:   row.MenuNameEn,
+			MenuSlug:     row.MenuSlug,
+			MenuSeqNo:    row.MenuSeqNo,
+			IsActive:     row.IsActive,
+			IsAdd:        row.IsAdd,
+			IsView:       row.IsView,
+			IsEdit:       row.IsEdit,
+			IsDelete:     row.IsDelete,
+		}
+		permission.SetActions(granted[row.ID])
+		permissions = append(permissions, permission)
+	}
+
+	if err := c.SetTagged(ctx, cacheKey, permissions, roleCacheTag, featureCacheTag); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func bumpRoleVersion(db *gorm.DB, roleId uuid.UUID) error {
+	return db.Model(&entities.Role{}).Where("id = ?", roleId).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+func bumpRoleVersionByRoleFeature(db *gorm.DB, roleFeatureId uuid.UUID) error {
+	return db.Model(&entities.Role{}).Where("id = (?)", db.Model(&entities.RoleFeature{}).Select("role_id").Where("id = ?", roleFeatureId)).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+func bumpRoleVersionsByFeatures(db *gorm.DB, featureIds []uuid.UUID) error {
+	return db.Model(&entities.Role{}).Where("id IN (?)", db.Model(&entities.RoleFeature{}).Select("role_id").Where("feature_id IN ?", featureIds)).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
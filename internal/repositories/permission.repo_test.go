@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+	"work01/internal/entities"
+
+	"github.com/google/uuid"
+)
+
+// The overrides here only touch features the roles already include, so
+// applyOverrides never needs the database. Features without a live override
+// keep their actions as the roles listed them.
+func TestApplyOverrides(t *testing.T) {
+	reports := uuid.New()
+	users := uuid.New()
+
+	permission := func(id uuid.UUID, slug string, actions ...string) entities.FeatureDTODetails {
+		p := entities.FeatureDTODetails{ID: id, MenuSlug: slug}
+		p.SetActions(actions)
+		return p
+	}
+	override := func(id uuid.UUID, action, effect string, expired bool) entities.ResPermissionOverride {
+		return entities.ResPermissionOverride{FeatureId: id, Action: action, Effect: effect, Expired: expired}
+	}
+
+	base := []entities.FeatureDTODetails{
+		permission(reports, "reports", entities.PermissionView),
+		permission(users, "users", entities.PermissionView, entities.PermissionEdit),
+	}
+
+	tests := []struct {
+		name           string
+		overrides      []entities.ResPermissionOverride
+		wantActions    map[string][]string
+		wantOverridden map[string][]string
+	}{
+		{
+			name:        "no overrides",
+			wantActions: map[string][]string{"reports": {"view"}, "users": {"view", "edit"}},
+		},
+		{
+			name:           "grant adds an action",
+			overrides:      []entities.ResPermissionOverride{override(reports, "export", entities.OverrideGrant, false)},
+			wantActions:    map[string][]string{"reports": {"export", "view"}, "users": {"view", "edit"}},
+			wantOverridden: map[string][]string{"reports": {"export"}},
+		},
+		{
+			name:           "deny removes an action the role grants",
+			overrides:      []entities.ResPermissionOverride{override(users, entities.PermissionEdit, entities.OverrideDeny, false)},
+			wantActions:    map[string][]string{"reports": {"view"}, "users": {"view"}},
+			wantOverridden: map[string][]string{"users": {"edit"}},
+		},
+		{
+			name:           "grant of an action already held",
+			overrides:      []entities.ResPermissionOverride{override(reports, entities.PermissionView, entities.OverrideGrant, false)},
+			wantActions:    map[string][]string{"reports": {"view"}, "users": {"view", "edit"}},
+			wantOverridden: map[string][]string{"reports": {"view"}},
+		},
+		{
+			name:        "expired overrides are ignored",
+			overrides:   []entities.ResPermissionOverride{override(users, entities.PermissionEdit, entities.OverrideDeny, true), override(reports, "export", entities.OverrideGrant, true)},
+			wantActions: map[string][]string{"reports": {"view"}, "users": {"view", "edit"}},
+		},
+		{
+			name:        "deny on a feature the roles do not include",
+			overrides:   []entities.ResPermissionOverride{override(uuid.New(), entities.PermissionView, entities.OverrideDeny, false)},
+			wantActions: map[string][]string{"reports": {"view"}, "users": {"view", "edit"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyOverrides(nil, base, tt.overrides)
+			if err != nil {
+				t.Fatalf("applyOverrides() error = %v", err)
+			}
+
+			if len(got) != len(base) {
+				t.Fatalf("applyOverrides() returned %d features, want %d", len(got), len(base))
+			}
+
+			for _, p := range got {
+				if !reflect.DeepEqual(p.Actions, tt.wantActions[p.MenuSlug]) {
+					t.Errorf("%s actions = %v, want %v", p.MenuSlug, p.Actions, tt.wantActions[p.MenuSlug])
+				}
+				if !reflect.DeepEqual(p.Overridden, tt.wantOverridden[p.MenuSlug]) {
+					t.Errorf("%s overridden = %v, want %v", p.MenuSlug, p.Overridden, tt.wantOverridden[p.MenuSlug])
+				}
+			}
+
+			if !reflect.DeepEqual(base[0].Actions, []string{"view"}) || !reflect.DeepEqual(base[1].Actions, []string{"view", "edit"}) {
+				t.Errorf("applyOverrides() changed the role permissions it was given")
+			}
+		})
+	}
+}
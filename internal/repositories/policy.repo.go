@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"fmt"
+	"work01/internal/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	PolicyRepository interface {
+		GetPolicies(resource string, action string) ([]entities.AccessPolicy, error)
+		GetAllPolicies() ([]entities.AccessPolicy, error)
+		GetPolicyById(id uuid.UUID) (*entities.AccessPolicy, error)
+		PolicyNameExists(name string, excludeId uuid.UUID) (bool, error)
+		CreatePolicy(policy *entities.AccessPolicy) error
+		UpdatePolicy(policy *entities.AccessPolicy) error
+		DeletePolicy(id uuid.UUID, deleteBy uuid.UUID) error
+		GetUserAttributes(id uuid.UUID) (*entities.UserAttributes, error)
+		GetAllOrgUnits() ([]entities.OrgUnit, error)
+		GetOrgUnitById(id uuid.UUID) (*entities.OrgUnit, error)
+		GetOrgUnitPath(id uuid.UUID) ([]uuid.UUID, error)
+		CreateOrgUnit(unit *entities.OrgUnit) error
+		UpdateOrgUnit(unit *entities.OrgUnit) error
+		DeleteOrgUnit(id uuid.UUID, deleteBy uuid.UUID) error
+	}
+
+	policyRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewPolicyRepository(db *gorm.DB) PolicyRepository {
+	return &policyRepository{db: db}
+}
+
+func (r *policyRepository) GetPolicies(resource string, action string) ([]entities.AccessPolicy, error) {
+	var policies []entities.AccessPolicy
+	if err := r.db.Where("resource = ? AND action = ? AND is_active", resource, action).Order("name").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func (r *policyRepository) GetAllPolicies() ([]entities.AccessPolicy, error) {
+	var policies []entities.AccessPolicy
+	if err := r.db.Order("resource, action, name").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func (r *policyRepository) GetPolicyById(id uuid.UUID) (*entities.AccessPolicy, error) {
+	var policy entities.AccessPolicy
+	if err := r.db.Where("id = ?", id).First(&policy).Error; err != nil {
+		return nil, fmt.Errorf("access policy not found")
+	}
+
+	return &policy, nil
+}
+
+func (r *policyRepository) PolicyNameExists(name string, excludeId uuid.UUID) (bool, error) {
+	var count int64
+	if err := r.db.Model(&entities.AccessPolicy{}).Where("name = ? AND id <> ?", name, excludeId).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *policyRepository) CreatePolicy(policy *entities.AccessPolicy) error {
+	return r.db.Create(policy).Error
+}
+
+func (r *policyRepository) UpdatePolicy(policy *entities.AccessPolicy) error {
+	return r.db.Model(policy).Select("name", "description", "resource", "action", "effect", "conditions", "message", "is_active", "updated_at", "updated_by").Updates(policy).Error
+}
+
+func (r *policyRepository) DeletePolicy(id uuid.UUID, deleteBy uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entities.AccessPolicy{}).Where("id = ?", id).Update("deleted_by", deleteBy).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&entities.AccessPolicy{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("access policy not found")
+		}
+
+		return nil
+	})
+}
+
+// GetUserAttributes loads what the policies know about a user: the roles in
+// force now and the org unit with its ancestors.
+func (r *policyRepository) GetUserAttributes(id uuid.UUID) (*entities.UserAttributes, error) {
+	var user entities.User
+	if err := r.db.Preload("Role").Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
+
+	roles, err := userRoles(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
+	attrs := entities.UserAttributes{
+		ID:        user.ID,
+		CreatedBy: user.CreatedBy,
+		Level:     user.RoleLevel(),
+		OrgUnitId: user.OrgUnitId,
+		IsActive:  user.IsActive == nil || *user.IsActive,
+	}
+	for _, role := range roles {
+		attrs.RoleIds = append(attrs.RoleIds, role.ID)
+		if role.SystemKey != nil {
+			attrs.SystemRoles = append(attrs.SystemRoles, *role.SystemKey)
+		}
+	}
+
+	if user.OrgUnitId != nil {
+		path, err := r.GetOrgUnitPath(*user.OrgUnitId)
+		if err != nil {
+			return nil, err
+		}
+		attrs.OrgUnitPath = path
+	}
+
+	return &attrs, nil
+}
+
+func (r *policyRepository) GetAllOrgUnits() ([]entities.OrgUnit, error) {
+	var units []entities.OrgUnit
+	if err := r.db.Order("name").Find(&units).Error; err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+func (r *policyRepository) GetOrgUnitById(id uuid.UUID) (*entities.OrgUnit, error) {
+	var unit entities.OrgUnit
+	if err := r.db.Where("id = ?", id).First(&unit).Error; err != nil {
+		return nil, fmt.Errorf("org unit not found")
+	}
+
+	return &unit, nil
+}
+
+// GetOrgUnitPath returns id followed by its ancestors up to the root.
+func (r *policyRepository) GetOrgUnitPath(id uuid.UUID) ([]uuid.UUID, error) {
+	var path []uuid.UUID
+	err := r.db.Raw(`WITH RECURSIVE path AS (
+			SELECT id, parent_id, 0 AS depth FROM org_units WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT org_units.id, org_units.parent_id, path.depth + 1 FROM org_units
+			JOIN path ON org_units.id = path.parent_id
+			WHERE org_units.deleted_at IS NULL AND path.depth < 64
+		)
+		SELECT id FROM path ORDER BY depth`, id).Scan(&path).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+func (r *policyRepository) CreateOrgUnit(unit *entities.OrgUnit) error {
+	return r.db.Create(unit).Error
+}
+
+func (r *policyRepository) UpdateOrgUnit(unit *entities.OrgUnit) error {
+	return r.db.Model(unit).Select("name", "parent_id", "updated_at", "updated_by").Updates(unit).Error
+}
+
+// DeleteOrgUnit removes a unit that has neither child units nor users.
+func (r *policyRepository) DeleteOrgUnit(id uuid.UUID, deleteBy uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&entities.OrgUnit{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return fmt.Errorf("can not delete an org unit that has child units")
+		}
+
+		var users int64
+		if err := tx.Model(&entities.User{}).Where("org_unit_id = ?", id).Count(&users).Error; err != nil {
+			return err
+		}
+		if users > 0 {
+			return fmt.Errorf("can not delete an org unit that has users")
+		}
+
+		if err := tx.Model(&entities.OrgUnit{}).Where("id = ?", id).Update("deleted_by", deleteBy).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&entities.OrgUnit{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("org unit not found")
+		}
+
+		return nil
+	})
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
+	"log"
+	"sort"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 
-	"github.com/go-redis/cache/v9"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -21,38 +21,65 @@ type (
 		RoleNameIsAlreadyExits(roleName string) (bool, error)
 		GetRoleLevelOfRoleUserByUserId(id uuid.UUID) (*entities.ResRoleLevel, error)
 		GetAllFetureDefault() ([]entities.Feature, error)
-		GetAllModify(ctx context.Context) ([]entities.ResAllRoleDetails, error)
+		GetAllModify(ctx context.Context, pq helpers.PageQuery) ([]entities.ResAllRoleDetails, int64, string, error)
 		Create(role *entities.Role, roleFeatures []entities.RoleFeature) error
-		Update(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) error
-		Delete(id uuid.UUID, delBy uuid.UUID) error
+		Update(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) ([]entities.FeatureInRole, error)
+		PreviewUpdate(ctx context.Context, roleId uuid.UUID, roleFeatures []entities.RoleFeature) ([]entities.FeatureChange, []entities.AffectedUser, error)
+		Delete(ctx context.Context, id uuid.UUID, delBy uuid.UUID) error
+		ReassignAndDelete(ctx context.Context, id uuid.UUID, toRoleId uuid.UUID, delBy uuid.UUID) (*entities.ResRoleReassignment, error)
+		Clone(ctx context.Context, sourceId uuid.UUID, role *entities.Role) ([]entities.FeatureInRole, error)
+		ApplyTemplate(ctx context.Context, roleId uuid.UUID, template entities.PermissionTemplate, featureIds []uuid.UUID) ([]entities.FeatureInRole, error)
+		GetAllTemplates() ([]entities.PermissionTemplate, error)
+		GetTemplateByName(name string) (*entities.PermissionTemplate, error)
+		CreateTemplate(template *entities.PermissionTemplate) error
 		CheckRoleHaveUserUsed(roleId uuid.UUID) (bool, error)
 	}
 
 	roleRepository struct {
 		db         *gorm.DB
-		redisCache *cache.Cache
+		redisCache *taggedCache
+	}
+
+	cachedRole struct {
+		Role     entities.Role
+		Features []entities.FeatureInRole
 	}
 )
 
-func NewRoleRepository(db *gorm.DB, redisClient *redis.Client) RoleRepository {
-	c := cache.New(&cache.Options{
-		Redis:      redisClient,
-		LocalCache: cache.NewTinyLFU(1000, time.Minute),
-	})
-	return &roleRepository{db: db, redisCache: c}
+func NewRoleRepository(db *gorm.DB, bus *InvalidationBus) RoleRepository {
+	return &roleRepository{db: db, redisCache: newTaggedCache(bus)}
 }
 
 func (r *roleRepository) Create(role *entities.Role, roleFeatures []entities.RoleFeature) error {
-	if err := r.db.Create(&role).Error; err != nil {
-		return err
-	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
 
-	for i := range roleFeatures {
-		roleFeatures[i].ID = uuid.New()
-		roleFeatures[i].RoleId = role.ID
+		if err := checkFeaturesExist(tx, roleFeatures); err != nil {
+			return err
+		}
+
+		for i := range roleFeatures {
+			roleFeatures[i].ID = uuid.New()
+			roleFeatures[i].RoleId = role.ID
+		}
+
+		if len(roleFeatures) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&roleFeatures).Error; err != nil {
+			return err
+		}
+
+		return saveRoleFeatureActions(tx, roleFeatures)
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := r.db.Create(&roleFeatures).Error; err != nil {
+	if err := r.redisCache.Invalidate(context.Background(), roleCacheTag); err != nil {
 		return err
 	}
 
@@ -62,55 +89,66 @@ func (r *roleRepository) Create(role *entities.Role, roleFeatures []entities.Rol
 func (r *roleRepository) GetById(ctx context.Context, id uuid.UUID) (*entities.Role, []entities.FeatureInRole, error) {
 	var roleOjb entities.Role
 	var roleFeatureDetails []entities.FeatureInRole
+	var cached cachedRole
 	cacheKey := fmt.Sprintf("role:%s", id)
 
-	if err := r.redisCache.Get(ctx, cacheKey, roleOjb); err == nil {
-		return &roleOjb, roleFeatureDetails, nil
+	if err := r.redisCache.Get(ctx, cacheKey, &cached); err == nil {
+		return &cached.Role, cached.Features, nil
 	}
 
-	if err := r.db.Preload("Features").Where("id=?", id).First(&roleOjb).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Features").Where("id=?", id).First(&roleOjb).Error; err != nil {
 		return nil, nil, err
 	}
 
-	for _, rf := range roleOjb.Features {
-		var roleFeatures entities.RoleFeature
-		if err := r.db.Where("role_id = ? AND feature_id = ?", roleOjb.ID, rf.ID).Preload("Feature").First(&roleFeatures).Error; err != nil {
-			return nil, nil, err
-		}
-		roleFeatureDetails = append(roleFeatureDetails, entities.FeatureInRole{
-			FeatureId:   roleFeatures.FeatureId,
-			FeatureName: roleFeatures.Feature.Name,
-			IsAdd:       roleFeatures.IsAdd,
-			IsView:      roleFeatures.IsView,
-			IsEdit:      roleFeatures.IsEdit,
-			IsDelete:    roleFeatures.IsDelete,
-		})
+	roleFeatureDetails, err := roleMatrix(r.db.WithContext(ctx), roleOjb.ID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey,
-		Value: roleOjb,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	cached = cachedRole{Role: roleOjb, Features: roleFeatureDetails}
+	if err := r.redisCache.SetTagged(ctx, cacheKey, cached, roleCacheTag, featureCacheTag); err != nil {
 		return nil, nil, err
 	}
 
 	return &roleOjb, roleFeatureDetails, nil
 }
 
-func (r *roleRepository) GetAllModify(ctx context.Context) ([]entities.ResAllRoleDetails, error) {
+func (r *roleRepository) GetAllModify(ctx context.Context, pq helpers.PageQuery) ([]entities.ResAllRoleDetails, int64, string, error) {
 	var roleOjbs []entities.Role
 	var roleRes []entities.ResAllRoleDetails
+	var total int64
+	var cursor string
+	var cached cachedPage[entities.ResAllRoleDetails]
+
+	cacheKey := listCacheKey(roleCacheTag, pq, nil)
+	if err := r.redisCache.Get(ctx, cacheKey, &cached); err == nil {
+		return cached.Items, cached.Total, cached.Cursor, nil
+	}
 
-	cacheKey := "roles_list"
+	query := r.db.WithContext(ctx).Model(&entities.Role{})
 
-	if err := r.redisCache.Get(ctx, cacheKey, roleOjbs); err == nil {
-		return roleRes, nil
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 	}
 
-	if err := r.db.Preload("Features").Preload("Users").Find(&roleOjbs).Error; err != nil {
-		return nil, err
+	if err := query.Scopes(pageScope("roles", pq)).Find(&roleOjbs).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	if pq.UseCursor {
+		roleOjbs, cursor = nextCursor(roleOjbs, pq.Size, func(role entities.Role) uuid.UUID { return role.ID })
+	}
+
+	roleIds := make([]uuid.UUID, 0, len(roleOjbs))
+	for _, role := range roleOjbs {
+		roleIds = append(roleIds, role.ID)
+	}
+
+	counts, err := countUsersByRole(r.db.WithContext(ctx), roleIds)
+	if err != nil {
+		return nil, 0, "", err
 	}
 
 	for _, role := range roleOjbs {
@@ -118,20 +156,23 @@ func (r *roleRepository) GetAllModify(ctx context.Context) ([]entities.ResAllRol
 			RoleID:     role.ID,
 			RoleName:   role.Name,
 			RoleLevel:  role.Level,
-			NumberUser: int32(len(role.Users)),
+			IsSystem:   role.IsSystem(),
+			NumberUser: int32(counts[role.ID]),
 		})
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey,
-		Value: roleRes,
-		TTL:   time.Minute * 10,
-	}); err != nil {
-		return nil, err
+	// the user counts change whenever a role window opens or closes
+	next, err := nextRoleBoundary(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	cached = cachedPage[entities.ResAllRoleDetails]{Items: roleRes, Total: total, Cursor: cursor}
+	if err := r.redisCache.SetTaggedUntil(ctx, cacheKey, cached, next, roleCacheTag, userCacheTag); err != nil {
+		return nil, 0, "", err
 	}
 
-	return roleRes, nil
+	return roleRes, total, cursor, nil
 }
 
 func (r *roleRepository) GetAllDefault() ([]entities.Role, error) {
@@ -154,78 +195,639 @@ func (r *roleRepository) GetAllFetureDefault() ([]entities.Feature, error) {
 	return features, nil
 }
 
-func (r *roleRepository) Update(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) error {
-	if err := r.db.Where("id=?", role.ID).Updates(&role).Error; err != nil {
+// Update saves role and, when roleFeatures is not nil, makes it the complete
+// permission matrix of the role: submitted features are inserted or updated
+// and any feature missing from the list is removed, all in one transaction.
+func (r *roleRepository) Update(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) ([]entities.FeatureInRole, error) {
+	var matrix []entities.FeatureInRole
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", role.ID).Updates(&role).Error; err != nil {
+			return err
+		}
+
+		if roleFeatures != nil {
+			if err := syncRoleFeatures(tx, role.ID, roleFeatures); err != nil {
+				return err
+			}
+		}
+
+		if err := bumpRoleVersion(tx, role.ID); err != nil {
+			return err
+		}
+
+		var err error
+		matrix, err = roleMatrix(tx, role.ID)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	for _, rf := range roleFeatures {
-		if err := r.db.Where("role_id = ? AND feature_id = ?", role.ID, rf.FeatureId).Updates(&rf).Error; err != nil {
+	if err := r.redisCache.Invalidate(ctx, roleCacheTag); err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+// PreviewUpdate works out what replacing the matrix of roleId with
+// roleFeatures would change without writing anything: the per-feature diff
+// and, for every current holder of the role, what they would actually gain
+// or lose. A nil roleFeatures keeps the matrix as it is.
+func (r *roleRepository) PreviewUpdate(ctx context.Context, roleId uuid.UUID, roleFeatures []entities.RoleFeature) ([]entities.FeatureChange, []entities.AffectedUser, error) {
+	current, err := roleMatrix(r.db.WithContext(ctx), roleId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before := make(map[uuid.UUID][]string, len(current))
+	names := make(map[uuid.UUID]string, len(current))
+	for _, f := range current {
+		before[f.FeatureId] = f.Actions
+		names[f.FeatureId] = f.FeatureName
+	}
+
+	after := before
+	if roleFeatures != nil {
+		if err := checkFeaturesExist(r.db.WithContext(ctx), roleFeatures); err != nil {
+			return nil, nil, err
+		}
+
+		after = make(map[uuid.UUID][]string, len(roleFeatures))
+		var unnamed []uuid.UUID
+		for _, rf := range roleFeatures {
+			after[rf.FeatureId] = rf.Actions
+			if _, ok := names[rf.FeatureId]; !ok {
+				unnamed = append(unnamed, rf.FeatureId)
+			}
+		}
+
+		if len(unnamed) > 0 {
+			var features []entities.Feature
+			if err := r.db.WithContext(ctx).Select("id, name").Where("id IN ?", unnamed).Find(&features).Error; err != nil {
+				return nil, nil, err
+			}
+			for _, f := range features {
+				names[f.ID] = f.Name
+			}
+		}
+	}
+
+	changes := matrixChanges(before, after, names)
+
+	var holders []entities.AffectedUser
+	if err := r.db.WithContext(ctx).Model(&entities.User{}).
+		Select("id AS user_id, first_name, last_name, email").
+		Where("id IN (?)", r.db.WithContext(ctx).Table(roleAssignments).Select("user_id").Where("role_id = ?", roleId)).
+		Order("first_name, last_name").
+		Scan(&holders).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for i := range holders {
+		holders[i].Changes, err = r.userChanges(ctx, holders[i].UserId, roleId, changes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return changes, holders, nil
+}
+
+// userChanges narrows changes of roleId down to what userId would notice:
+// actions also granted by another of their roles, or decided by an active
+// override, do not change for them.
+func (r *roleRepository) userChanges(ctx context.Context, userId uuid.UUID, roleId uuid.UUID, changes []entities.FeatureChange) ([]entities.FeatureChange, error) {
+	roles, err := userRoles(r.db.WithContext(ctx), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	unaffected := map[uuid.UUID][]string{}
+	for _, role := range roles {
+		if role.ID == roleId {
+			continue
+		}
+
+		permissions, err := effectivePermissions(ctx, r.db.WithContext(ctx), r.redisCache, role)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range permissions {
+			unaffected[p.ID] = unionActions(unaffected[p.ID], p.Actions)
+		}
+	}
+
+	overrides, err := userOverrides(r.db.WithContext(ctx), userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if !o.Expired {
+			unaffected[o.FeatureId] = unionActions(unaffected[o.FeatureId], []string{o.Action})
+		}
+	}
+
+	userChanges := []entities.FeatureChange{}
+	for _, c := range changes {
+		granted := withoutActions(c.Granted, unaffected[c.FeatureId])
+		revoked := withoutActions(c.Revoked, unaffected[c.FeatureId])
+		if len(granted) > 0 || len(revoked) > 0 {
+			userChanges = append(userChanges, entities.FeatureChange{FeatureId: c.FeatureId, FeatureName: c.FeatureName, Granted: granted, Revoked: revoked})
+		}
+	}
+
+	return userChanges, nil
+}
+
+// matrixChanges diffs two matrices keyed by feature, ordered by feature name.
+func matrixChanges(before, after map[uuid.UUID][]string, names map[uuid.UUID]string) []entities.FeatureChange {
+	changes := []entities.FeatureChange{}
+	seen := map[uuid.UUID]bool{}
+	for _, m := range []map[uuid.UUID][]string{before, after} {
+		for id := range m {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			granted := withoutActions(after[id], before[id])
+			revoked := withoutActions(before[id], after[id])
+			if len(granted) > 0 || len(revoked) > 0 {
+				changes = append(changes, entities.FeatureChange{FeatureId: id, FeatureName: names[id], Granted: granted, Revoked: revoked})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].FeatureName != changes[j].FeatureName {
+			return changes[i].FeatureName < changes[j].FeatureName
+		}
+		return changes[i].FeatureId.String() < changes[j].FeatureId.String()
+	})
+
+	return changes
+}
+
+// withoutActions returns the actions of list that are not in remove.
+func withoutActions(list []string, remove []string) []string {
+	kept := []string{}
+	for _, action := range list {
+		if !entities.HasAction(remove, action) {
+			kept = append(kept, action)
+		}
+	}
+
+	return kept
+}
+
+// Clone creates role with a copy of the permission matrix of sourceId.
+func (r *roleRepository) Clone(ctx context.Context, sourceId uuid.UUID, role *entities.Role) ([]entities.FeatureInRole, error) {
+	var matrix []entities.FeatureInRole
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var source []entities.RoleFeature
+		if err := tx.Where("role_id = ?", sourceId).Find(&source).Error; err != nil {
 			return err
 		}
+
+		if err := loadRoleFeatureActions(tx, source); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+
+		roleFeatures := make([]entities.RoleFeature, 0, len(source))
+		for _, rf := range source {
+			roleFeatures = append(roleFeatures, entities.RoleFeature{
+				ID:        uuid.New(),
+				RoleId:    role.ID,
+				FeatureId: rf.FeatureId,
+				IsAdd:     rf.IsAdd,
+				IsView:    rf.IsView,
+				IsEdit:    rf.IsEdit,
+				IsDelete:  rf.IsDelete,
+				Actions:   rf.Actions,
+			})
+		}
+
+		if len(roleFeatures) > 0 {
+			if err := tx.Create(&roleFeatures).Error; err != nil {
+				return err
+			}
+
+			if err := saveRoleFeatureActions(tx, roleFeatures); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		matrix, err = roleMatrix(tx, role.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	cacheKey1 := fmt.Sprintf("role:%s", role.ID)
-	if err := r.redisCache.Delete(ctx, cacheKey1); err != nil {
+	if err := r.redisCache.Invalidate(ctx, roleCacheTag); err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+// ApplyTemplate sets the flags of template on featureIds, or on every feature
+// when featureIds is empty. Features not listed keep their permissions, and
+// actions outside the standard four are never touched by a template.
+func (r *roleRepository) ApplyTemplate(ctx context.Context, roleId uuid.UUID, template entities.PermissionTemplate, featureIds []uuid.UUID) ([]entities.FeatureInRole, error) {
+	var matrix []entities.FeatureInRole
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(featureIds) == 0 {
+			if err := tx.Model(&entities.Feature{}).Pluck("id", &featureIds).Error; err != nil {
+				return err
+			}
+		}
+
+		var roleFeatures []entities.RoleFeature
+		if err := tx.Where("role_id = ? AND feature_id IN (?)", roleId, tx.Model(&entities.Feature{}).Select("id")).Find(&roleFeatures).Error; err != nil {
+			return err
+		}
+
+		if err := loadRoleFeatureActions(tx, roleFeatures); err != nil {
+			return err
+		}
+
+		declared, err := declaredActions(tx, featureIds)
+		if err != nil {
+			return err
+		}
+
+		templateActions := entities.ActionsFromFlags(template.IsView, template.IsAdd, template.IsEdit, template.IsDelete)
+		withTemplate := func(featureId uuid.UUID, current []string) []string {
+			var actions []string
+			for _, action := range current {
+				if !entities.HasAction(entities.StandardActions, action) {
+					actions = append(actions, action)
+				}
+			}
+			for _, action := range templateActions {
+				if entities.HasAction(declared[featureId], action) {
+					actions = append(actions, action)
+				}
+			}
+			return unionActions(actions)
+		}
+
+		applied := make(map[uuid.UUID]bool, len(featureIds))
+		for _, id := range featureIds {
+			applied[id] = true
+		}
+
+		for i := range roleFeatures {
+			if applied[roleFeatures[i].FeatureId] {
+				roleFeatures[i].Actions = withTemplate(roleFeatures[i].FeatureId, roleFeatures[i].Actions)
+				delete(applied, roleFeatures[i].FeatureId)
+			}
+		}
+
+		for _, id := range featureIds {
+			if applied[id] {
+				roleFeatures = append(roleFeatures, entities.RoleFeature{
+					FeatureId: id,
+					Actions:   withTemplate(id, nil),
+				})
+			}
+		}
+
+		if err := syncRoleFeatures(tx, roleId, roleFeatures); err != nil {
+			return err
+		}
+
+		if err := bumpRoleVersion(tx, roleId); err != nil {
+			return err
+		}
+
+		matrix, err = roleMatrix(tx, roleId)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, roleCacheTag); err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+func (r *roleRepository) GetAllTemplates() ([]entities.PermissionTemplate, error) {
+	var templates []entities.PermissionTemplate
+	if err := r.db.Order("name").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (r *roleRepository) GetTemplateByName(name string) (*entities.PermissionTemplate, error) {
+	var template entities.PermissionTemplate
+	if err := r.db.Where("name=?", name).First(&template).Error; err != nil {
+		return nil, err
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey1,
-		Value: role,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	return &template, nil
+}
+
+func (r *roleRepository) CreateTemplate(template *entities.PermissionTemplate) error {
+	return r.db.Create(&template).Error
+}
+
+func syncRoleFeatures(tx *gorm.DB, roleId uuid.UUID, roleFeatures []entities.RoleFeature) error {
+	if err := checkFeaturesExist(tx, roleFeatures); err != nil {
 		return err
 	}
 
-	var roleOjbs []entities.Role
-	var roleRes []entities.ResAllRoleDetails
+	// Rows of soft-deleted features are left alone so restoring the feature
+	// brings its permissions back.
+	var existing []entities.RoleFeature
+	if err := tx.Where("role_id = ? AND feature_id IN (?)", roleId, tx.Model(&entities.Feature{}).Select("id")).Find(&existing).Error; err != nil {
+		return err
+	}
 
-	cacheKey2 := "roles_list"
+	if err := loadRoleFeatureActions(tx, existing); err != nil {
+		return err
+	}
 
-	if err := r.redisCache.Delete(ctx, cacheKey2); err != nil {
+	current := make(map[uuid.UUID]entities.RoleFeature, len(existing))
+	for _, rf := range existing {
+		current[rf.FeatureId] = rf
+	}
+
+	revoked := make(map[uuid.UUID][]string, len(existing))
+	requested := make(map[uuid.UUID][]string, len(roleFeatures))
+	for _, rf := range roleFeatures {
+		requested[rf.FeatureId] = rf.Actions
+	}
+	for _, rf := range existing {
+		revoked[rf.FeatureId] = withoutActions(rf.Actions, requested[rf.FeatureId])
+	}
+	if err := keepSystemRoleGrants(tx, roleId, revoked); err != nil {
 		return err
 	}
 
-	if err := r.db.Preload("Users").Find(&roleOjbs).Error; err != nil {
+	var inserts, updates []entities.RoleFeature
+	for _, rf := range roleFeatures {
+		old, ok := current[rf.FeatureId]
+		if !ok {
+			rf.ID = uuid.New()
+			rf.RoleId = roleId
+			inserts = append(inserts, rf)
+			continue
+		}
+		delete(current, rf.FeatureId)
+
+		if sameActions(old.Actions, rf.Actions) {
+			continue
+		}
+
+		if err := tx.Model(&entities.RoleFeature{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+			"is_add":    boolValue(rf.IsAdd),
+			"is_view":   boolValue(rf.IsView),
+			"is_edit":   boolValue(rf.IsEdit),
+			"is_delete": boolValue(rf.IsDelete),
+		}).Error; err != nil {
+			return err
+		}
+
+		rf.ID = old.ID
+		updates = append(updates, rf)
+	}
+
+	if len(inserts) > 0 {
+		if err := tx.Create(&inserts).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := saveRoleFeatureActions(tx, append(inserts, updates...)); err != nil {
 		return err
 	}
 
-	for _, role := range roleOjbs {
-		roleRes = append(roleRes, entities.ResAllRoleDetails{
-			RoleID:     role.ID,
-			RoleName:   role.Name,
-			RoleLevel:  role.Level,
-			NumberUser: int32(len(role.Users)),
-		})
+	if len(current) > 0 {
+		var ids []uuid.UUID
+		for _, rf := range current {
+			ids = append(ids, rf.ID)
+		}
+
+		if err := tx.Where("role_feature_id IN ?", ids).Delete(&entities.RoleFeatureAction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id IN ?", ids).Delete(&entities.RoleFeature{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepSystemRoleGrants refuses a change to roleId that would revoke actions
+// when roleId is a system role. revoked holds the dropped actions by feature.
+func keepSystemRoleGrants(tx *gorm.DB, roleId uuid.UUID, revoked map[uuid.UUID][]string) error {
+	reduced := false
+	for _, actions := range revoked {
+		if len(actions) > 0 {
+			reduced = true
+			break
+		}
+	}
+	if !reduced {
+		return nil
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey2,
-		Value: roleRes,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	var role entities.Role
+	if err := tx.Select("id, system_key").Where("id = ?", roleId).Limit(1).Find(&role).Error; err != nil {
 		return err
 	}
 
+	if role.IsSystem() {
+		return helpers.Mark(fmt.Errorf("can not remove permissions from a system role"), helpers.ErrInvalid)
+	}
+
 	return nil
 }
 
-func (r *roleRepository) Delete(id uuid.UUID, delBy uuid.UUID) error {
-	if err := r.db.Model(&entities.Role{}).Where("id = ?", id).Updates(map[string]interface{}{
+// MarkSystemRoles flags the super administrator role of databases created
+// before system roles existed, found by its historical name. It does nothing
+// once a role carries the key, so renaming that role later is harmless.
+func MarkSystemRoles(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&entities.Role{}).Where("system_key = ?", entities.SystemRoleSuperAdministrator).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return db.Exec(`UPDATE roles SET system_key = ? WHERE id = (
+		SELECT id FROM roles WHERE name = ? AND deleted_at IS NULL ORDER BY created_at LIMIT 1)`,
+		entities.SystemRoleSuperAdministrator, "Super Administrator").Error
+}
+
+// checkFeaturesExist rejects matrices naming a feature twice, naming one that
+// does not exist or granting an action the feature does not declare, before
+// anything is written. Grants sent only as legacy flags are normalized to
+// actions on the way.
+func checkFeaturesExist(tx *gorm.DB, roleFeatures []entities.RoleFeature) error {
+	if len(roleFeatures) == 0 {
+		return nil
+	}
+
+	for i := range roleFeatures {
+		roleFeatures[i].NormalizeActions()
+	}
+
+	ids := make([]uuid.UUID, 0, len(roleFeatures))
+	seen := make(map[uuid.UUID]bool, len(roleFeatures))
+	for _, rf := range roleFeatures {
+		if seen[rf.FeatureId] {
+			return helpers.Mark(fmt.Errorf("feature %s is listed more than once", rf.FeatureId), helpers.ErrInvalid)
+		}
+		seen[rf.FeatureId] = true
+		ids = append(ids, rf.FeatureId)
+	}
+
+	var total int64
+	if err := tx.Model(&entities.Feature{}).Where("id IN ?", ids).Count(&total).Error; err != nil {
+		return err
+	}
+
+	if int(total) != len(ids) {
+		return helpers.Mark(fmt.Errorf("feature not found"), helpers.ErrInvalid)
+	}
+
+	return checkActionsDeclared(tx, roleFeatures)
+}
+
+func roleMatrix(db *gorm.DB, roleId uuid.UUID) ([]entities.FeatureInRole, error) {
+	matrix := []entities.FeatureInRole{}
+	if err := db.Model(&entities.RoleFeature{}).
+		Select("role_features.feature_id, features.name AS feature_name, role_features.is_add, role_features.is_view, role_features.is_edit, role_features.is_delete").
+		Joins("JOIN features ON features.id = role_features.feature_id AND features.deleted_at IS NULL").
+		Where("role_features.role_id = ?", roleId).
+		Order("features.menu_seq_no, features.name").
+		Scan(&matrix).Error; err != nil {
+		return nil, err
+	}
+
+	granted, err := grantedActions(db, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matrix {
+		matrix[i].Actions = granted[matrix[i].FeatureId]
+	}
+
+	return matrix, nil
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func (r *roleRepository) Delete(ctx context.Context, id uuid.UUID, delBy uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Model(&entities.Role{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"deleted_by": delBy,
 	}).Error; err != nil {
 		return err
 	}
 
-	if err := r.db.Delete(&entities.Role{}, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Delete(&entities.Role{}, id).Error; err != nil {
 		return err
 	}
+
+	if err := r.redisCache.Invalidate(ctx, roleCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// ReassignAndDelete moves every user of role id to toRoleId, as primary,
+// fallback or additional role, and deletes the role in the same transaction.
+// The moved users are signed out afterwards so their tokens pick up the new
+// role. Every user is tried; the ones that could not be signed out are
+// reported in the result and do not undo the delete.
+func (r *roleRepository) ReassignAndDelete(ctx context.Context, id uuid.UUID, toRoleId uuid.UUID, delBy uuid.UUID) (*entities.ResRoleReassignment, error) {
+	var userIds []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(`SELECT id FROM users WHERE deleted_at IS NULL AND (role_id = ? OR fallback_role_id = ?)
+			UNION
+			SELECT user_id FROM user_roles WHERE role_id = ? AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)`,
+			id, id, id).Scan(&userIds).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entities.User{}).Where("role_id = ?", id).Update("role_id", toRoleId).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entities.User{}).Where("fallback_role_id = ?", id).Update("fallback_role_id", toRoleId).Error; err != nil {
+			return err
+		}
+
+		// a user that already holds toRoleId keeps that assignment
+		if err := tx.Where("role_id = ? AND user_id IN (?)", id,
+			tx.Model(&entities.UserRole{}).Select("user_id").Where("role_id = ?", toRoleId)).Delete(&entities.UserRole{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entities.UserRole{}).Where("role_id = ?", id).Update("role_id", toRoleId).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entities.Role{}).Where("id = ?", id).Update("deleted_by", delBy).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&entities.Role{}, id).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, entities.AuditRoleReassigned, "role", id, &delBy, entities.RoleReassignment{ToRoleId: toRoleId, UserIds: userIds})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the role is already deleted, so a failed eviction must not be reported
+	// as a failed delete
+	if err := r.redisCache.Invalidate(ctx, roleCacheTag, userCacheTag); err != nil {
+		log.Printf("role %s was deleted but the cache was not invalidated: %v", id, err)
+	}
+
+	res := &entities.ResRoleReassignment{
+		ToRoleId:                  toRoleId,
+		UserIds:                   userIds,
+		SessionRevocationFailures: []entities.SessionRevocationFailure{},
+	}
+
+	for _, userId := range userIds {
+		if err := revokeSessions(ctx, r.db.WithContext(ctx), r.redisCache, userId, &delBy); err != nil {
+			res.SessionRevocationFailures = append(res.SessionRevocationFailures, entities.SessionRevocationFailure{UserId: userId, Error: err.Error()})
+		}
+	}
+
+	return res, nil
+}
+
 func (r *roleRepository) RoleNameIsAlreadyExitsUpdate(roleId uuid.UUID, roleName string) (bool, error) {
 	var role entities.Role
 	if err := r.db.Where("name=? AND id != ?", roleName, roleId).First(&role).Error; err != nil {
@@ -256,25 +858,38 @@ func (r *roleRepository) GetRoleLevelOfRoleUserByUserId(id uuid.UUID) (*entities
 		return nil, err
 	}
 
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
+
+	roles, err := userRoles(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
 	res := entities.ResRoleLevel{
-		RoleLevel: user.Role.Level,
+		RoleLevel: user.RoleLevel(),
 	}
 
 	return &res, nil
 }
 
+// CheckRoleHaveUserUsed also counts assignments that are scheduled, expired
+// but not yet cleaned up, or waiting as a fallback.
 func (r *roleRepository) CheckRoleHaveUserUsed(roleId uuid.UUID) (bool, error) {
-	var roleOjb entities.Role
-	if err := r.db.Preload("Features").Preload("Users").Where("id=?", roleId).Find(&roleOjb).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, nil
-		}
+	var total int64
+	if err := r.db.Model(&entities.User{}).Where("role_id = ? OR fallback_role_id = ?", roleId, roleId).Count(&total).Error; err != nil {
 		return false, err
 	}
 
-	if len(roleOjb.Users) > 0 {
+	if total > 0 {
 		return true, nil
 	}
 
-	return false, nil
+	if err := r.db.Model(&entities.UserRole{}).Where("role_id = ? AND user_id IN (?)", roleId, r.db.Model(&entities.User{}).Select("id")).Count(&total).Error; err != nil {
+		return false, err
+	}
+
+	return total > 0, nil
 }
@@ -2,12 +2,10 @@ package repositories
 
 import (
 	"context"
-	"time"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 
-	"github.com/go-redis/cache/v9"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -15,62 +13,201 @@ type (
 	RoleFeatureRepository interface {
 		Create(roleFeature *entities.RoleFeature) error
 		GetById(ctx context.Context, id uuid.UUID) (*entities.RoleFeature, error)
-		GetAll(ctx context.Context) ([]entities.RoleFeature, error)
+		GetAll(ctx context.Context, pq helpers.PageQuery) ([]entities.RoleFeature, int64, string, error)
 		Update(roleFeature *entities.RoleFeature) error
 		Delete(id uuid.UUID) error
 	}
 
 	roleFeatureRepository struct {
 		db         *gorm.DB
-		redisCache *cache.Cache
+		redisCache *taggedCache
 	}
 )
 
-func NewRoleFeatureRepository(db *gorm.DB, redisClient *redis.Client) RoleFeatureRepository {
-	c := cache.New(&cache.Options{
-		Redis:      redisClient,
-		LocalCache: cache.NewTinyLFU(1000, time.Minute),
-	})
-	return &roleFeatureRepository{db: db, redisCache: c}
+func NewRoleFeatureRepository(db *gorm.DB, bus *InvalidationBus) RoleFeatureRepository {
+	return &roleFeatureRepository{db: db, redisCache: newTaggedCache(bus)}
 }
 
 func (r *roleFeatureRepository) Create(roleFeature *entities.RoleFeature) error {
-	if err := r.db.Create(&roleFeature).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		roleFeatures := []entities.RoleFeature{*roleFeature}
+		if err := checkFeaturesExist(tx, roleFeatures); err != nil {
+			return err
+		}
+		*roleFeature = roleFeatures[0]
+
+		if err := tx.Create(&roleFeature).Error; err != nil {
+			return err
+		}
+
+		if err := saveRoleFeatureActions(tx, []entities.RoleFeature{*roleFeature}); err != nil {
+			return err
+		}
+
+		return bumpRoleVersion(tx, roleFeature.RoleId)
+	})
+	if err != nil {
 		return err
 	}
+
+	if err := r.redisCache.Invalidate(context.Background(), roleCacheTag, featureCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (r *roleFeatureRepository) GetById(ctx context.Context, id uuid.UUID) (*entities.RoleFeature, error) {
 	var roleFeature entities.RoleFeature
 
-	if err := r.db.Preload("Feature").First(&roleFeature, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Feature").First(&roleFeature, id).Error; err != nil {
+		return nil, err
+	}
+
+	roleFeatures := []entities.RoleFeature{roleFeature}
+	if err := loadRoleFeatureActions(r.db.WithContext(ctx), roleFeatures); err != nil {
 		return nil, err
 	}
 
-	return &roleFeature, nil
+	return &roleFeatures[0], nil
 }
 
-func (r *roleFeatureRepository) GetAll(ctx context.Context) ([]entities.RoleFeature, error) {
+func (r *roleFeatureRepository) GetAll(ctx context.Context, pq helpers.PageQuery) ([]entities.RoleFeature, int64, string, error) {
 	var roleFeature []entities.RoleFeature
+	var total int64
+	var cursor string
 
-	if err := r.db.Preload("Feature").Find(&roleFeature).Error; err != nil {
-		return nil, err
+	query := r.db.WithContext(ctx).Model(&entities.RoleFeature{})
+
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	if err := query.Preload("Feature").Scopes(pageScope("role_features", pq)).Find(&roleFeature).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	if err := loadRoleFeatureActions(r.db.WithContext(ctx), roleFeature); err != nil {
+		return nil, 0, "", err
+	}
+
+	if pq.UseCursor {
+		roleFeature, cursor = nextCursor(roleFeature, pq.Size, func(rf entities.RoleFeature) uuid.UUID { return rf.ID })
 	}
 
-	return roleFeature, nil
+	return roleFeature, total, cursor, nil
 }
 
+// Update changes the grants of a row. Flags left out of the request keep
+// their current value unless the complete Actions list is sent.
 func (r *roleFeatureRepository) Update(roleFeature *entities.RoleFeature) error {
-	if err := r.db.Where("id=?", roleFeature.ID).Updates(&roleFeature).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current entities.RoleFeature
+		if err := tx.First(&current, roleFeature.ID).Error; err != nil {
+			return err
+		}
+
+		roleFeatures := []entities.RoleFeature{current}
+		if err := loadRoleFeatureActions(tx, roleFeatures); err != nil {
+			return err
+		}
+
+		if roleFeature.Actions == nil {
+			roleFeature.Actions = roleFeatures[0].Actions
+			for i, flag := range []*bool{roleFeature.IsView, roleFeature.IsAdd, roleFeature.IsEdit, roleFeature.IsDelete} {
+				if flag == nil {
+					continue
+				}
+
+				action := entities.StandardActions[i]
+				actions := []string{}
+				for _, a := range roleFeature.Actions {
+					if a != action {
+						actions = append(actions, a)
+					}
+				}
+				if *flag {
+					actions = append(actions, action)
+				}
+				roleFeature.Actions = actions
+			}
+		}
+
+		if roleFeature.FeatureId == uuid.Nil {
+			roleFeature.FeatureId = current.FeatureId
+		}
+
+		previous := roleFeatures[0].Actions
+		roleFeatures = []entities.RoleFeature{*roleFeature}
+		if err := checkFeaturesExist(tx, roleFeatures); err != nil {
+			return err
+		}
+		*roleFeature = roleFeatures[0]
+
+		if err := keepSystemRoleGrants(tx, current.RoleId, map[uuid.UUID][]string{
+			current.FeatureId: withoutActions(previous, roleFeature.Actions),
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Where("id=?", roleFeature.ID).Updates(&roleFeature).Error; err != nil {
+			return err
+		}
+
+		if err := saveRoleFeatureActions(tx, roleFeatures); err != nil {
+			return err
+		}
+
+		return bumpRoleVersionByRoleFeature(tx, roleFeature.ID)
+	})
+	if err != nil {
 		return err
 	}
+
+	if err := r.redisCache.Invalidate(context.Background(), roleCacheTag, featureCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (r *roleFeatureRepository) Delete(id uuid.UUID) error {
-	if err := r.db.Delete(&entities.RoleFeature{}, id).Error; err != nil {
+	var current entities.RoleFeature
+	if err := r.db.First(&current, id).Error; err != nil {
+		return err
+	}
+
+	roleFeatures := []entities.RoleFeature{current}
+	if err := loadRoleFeatureActions(r.db, roleFeatures); err != nil {
 		return err
 	}
+
+	if err := keepSystemRoleGrants(r.db, current.RoleId, map[uuid.UUID][]string{current.FeatureId: roleFeatures[0].Actions}); err != nil {
+		return err
+	}
+
+	// the version is bumped after the rows are gone and in the same
+	// transaction, so no reader can cache the old grants under the new version
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_feature_id = ?", id).Delete(&entities.RoleFeatureAction{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&entities.RoleFeature{}, id).Error; err != nil {
+			return err
+		}
+
+		return bumpRoleVersion(tx, current.RoleId)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(context.Background(), roleCacheTag, featureCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
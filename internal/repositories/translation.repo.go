@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"work01/internal/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	TranslationRepository interface {
+		GetAll() ([]entities.Translation, error)
+		GetByLang(lang string) ([]entities.Translation, error)
+		Upsert(translation *entities.Translation) error
+	}
+
+	translationRepository struct {
+		db *gorm.DB
+	}
+)
+
+func NewTranslationRepository(db *gorm.DB) TranslationRepository {
+	return &translationRepository{db: db}
+}
+
+func (r *translationRepository) GetAll() ([]entities.Translation, error) {
+	var translations []entities.Translation
+	if err := r.db.Order("lang, namespace, key").Find(&translations).Error; err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+func (r *translationRepository) GetByLang(lang string) ([]entities.Translation, error) {
+	var translations []entities.Translation
+	if err := r.db.Where("lang=?", lang).Order("namespace, key").Find(&translations).Error; err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+func (r *translationRepository) Upsert(translation *entities.Translation) error {
+	if translation.ID == uuid.Nil {
+		translation.ID = uuid.New()
+	}
+
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lang"}, {Name: "namespace"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at", "updated_by"}),
+	}).Create(translation).Error
+}
@@ -2,50 +2,60 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 
 	"github.com/go-redis/cache/v9"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	UserRepository interface {
 		Create(user *entities.User) error
+		CreateWithRoles(ctx context.Context, user *entities.User, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment) error
 		GetById(ctx context.Context, id uuid.UUID) (*entities.ResUserDTO, error)
 		GetProfileUser(id uuid.UUID) (*entities.User, error)
 		GetAvatarUserById(id uuid.UUID) (*entities.ResAvatar, error)
 		GetRoleUserById(id uuid.UUID) (*entities.User, error)
 		GetAllNoPage() ([]entities.ResUsersNoPage, error)
-		GetAllWithPage(ctx context.Context, page, size int, roleId, isActive string, phoneNumber string, fullName string) ([]entities.ResAllUserDTOs, int64, error)
+		GetAllWithPage(ctx context.Context, pq helpers.PageQuery, roleId, isActive string, phoneNumber string, fullName string) ([]entities.ResAllUserDTOs, int64, string, error)
 		Update(ctx context.Context, user *entities.User) error
+		UpdateWithRoles(ctx context.Context, user *entities.User, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment) error
 		Delete(ctx context.Context, id uuid.UUID, deleteBy uuid.UUID) error
 		GetUserByEmail(email string) (*entities.User, error)
 		GetRoleByRoleId(id uuid.UUID) (*entities.Role, error)
+		GetRolesByIds(ids []uuid.UUID) ([]entities.Role, error)
+		GetRoleBySystemKey(key string) (*entities.Role, error)
+		SetRoles(ctx context.Context, userId uuid.UUID, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment, by uuid.UUID) error
+		ExpireRoleAssignments(ctx context.Context, now time.Time) ([]entities.RoleExpiry, error)
+		RevokeSessions(ctx context.Context, userId uuid.UUID, actorId *uuid.UUID) error
+		GetPermissionOverrides(userId uuid.UUID) ([]entities.ResPermissionOverride, error)
+		SavePermissionOverride(override *entities.PermissionOverride) error
+		DeletePermissionOverride(userId uuid.UUID, id uuid.UUID) error
 		IsEmailExists(email string) (bool, error)
 		IsPhoneExists(phone string) (bool, error)
 		IsEmailExistsForUpdate(email string, id uuid.UUID) (bool, error)
 		IsPhoneExistsForUpdate(phone string, id uuid.UUID) (bool, error)
 		IsSuperAdministrator(id uuid.UUID) (bool, error)
+		CreateSuperAdministrator(ctx context.Context, user *entities.User) error
 		CheckThisUserHaveDataInAuth(userId uuid.UUID) (*entities.Authorization, bool, error)
 		DeleteAuthAfterDeleteUser(id uuid.UUID, deleteBy uuid.UUID) error
 	}
 
 	userRepository struct {
 		db         *gorm.DB
-		redisCache *cache.Cache
+		redisCache *taggedCache
 	}
 )
 
-func NewUserRepository(db *gorm.DB, redisClient *redis.Client) UserRepository {
-	c := cache.New(&cache.Options{
-		Redis:      redisClient,
-		LocalCache: cache.NewTinyLFU(1000, time.Minute),
-	})
-	return &userRepository{db: db, redisCache: c}
+func NewUserRepository(db *gorm.DB, bus *InvalidationBus) UserRepository {
+	return &userRepository{db: db, redisCache: newTaggedCache(bus)}
 }
 
 func (r *userRepository) Create(user *entities.User) error {
@@ -54,6 +64,31 @@ func (r *userRepository) Create(user *entities.User) error {
 		return err
 	}
 
+	if err := r.redisCache.Invalidate(context.Background(), userCacheTag); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateWithRoles creates user and gives it its roles in one transaction, so
+// a user is never left with only part of what was asked for.
+func (r *userRepository) CreateWithRoles(ctx context.Context, user *entities.User, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		return setRoles(tx, user.ID, primary, fallbackId, assignments, user.CreatedBy)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -104,6 +139,16 @@ func (r *userRepository) GetRoleUserById(id uuid.UUID) (*entities.User, error) {
 		return nil, err
 	}
 
+	if err := resolvePrimaryRole(r.db, &user); err != nil {
+		return nil, err
+	}
+
+	roles, err := userRoles(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
 	return &user, nil
 }
 
@@ -119,39 +164,35 @@ func (r *userRepository) GetProfileUser(id uuid.UUID) (*entities.User, error) {
 func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*entities.ResUserDTO, error) {
 	var user entities.User
 	var userDTO entities.ResUserDTO
-	var mergedPermissions []entities.FeatureDTODetails
 
 	cacheKey := fmt.Sprintf("user:%s", id)
 
-	if err := r.redisCache.Get(ctx, cacheKey, userDTO); err == nil {
-		return &userDTO, nil
+	if err := r.redisCache.Get(ctx, cacheKey, &userDTO); err == nil {
+		return r.withOverrides(&userDTO)
 	}
 
-	if err := r.db.Preload("Role.Features").Where("id=?", id).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Role").Where("id=?", id).First(&user).Error; err != nil {
 		return nil, err
 	}
 
-	for _, feature := range user.Role.Features {
-		var roleFeature entities.RoleFeature
-		if err := r.db.Where("role_id = ? AND feature_id = ?", user.Role.ID, feature.ID).First(&roleFeature).Error; err != nil {
-			return nil, err
-		}
+	if err := resolvePrimaryRole(r.db.WithContext(ctx), &user); err != nil {
+		return nil, err
+	}
 
-		mergedPermissions = append(mergedPermissions, entities.FeatureDTODetails{
-			ID:           feature.ID,
-			Name:         feature.Name,
-			ParentMenuId: feature.ParentMenuId,
-			MenuIcon:     returnNull(feature.MenuIcon),
-			MenuNameTh:   feature.MenuNameTh,
-			MenuNameEn:   feature.MenuNameEn,
-			MenuSlug:     feature.MenuSlug,
-			MenuSeqNo:    feature.MenuSeqNo,
-			IsActive:     feature.IsActive,
-			IsAdd:        roleFeature.IsAdd,
-			IsView:       roleFeature.IsView,
-			IsEdit:       roleFeature.IsEdit,
-			IsDelete:     roleFeature.IsDelete,
-		})
+	roles, err := userRoles(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
+	ends, err := roleValidUntil(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	mergedPermissions, err := userPermissions(ctx, r.db.WithContext(ctx), r.redisCache, roles)
+	if err != nil {
+		return nil, err
 	}
 
 	userDTO = entities.ResUserDTO{
@@ -163,7 +204,11 @@ func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*entities.R
 		Avatar:            returnNull(user.Avatar),
 		RoleId:            user.Role.ID,
 		RoleName:          user.Role.Name,
-		RoleLevel:         user.Role.Level,
+		RoleLevel:         user.RoleLevel(),
+		RoleValidUntil:    user.RoleValidUntil,
+		FallbackRoleId:    user.FallbackRoleId,
+		OrgUnitId:         user.OrgUnitId,
+		Roles:             rolesInUser(roles, ends),
 		TwoFactorEnabled:  *user.TwoFactorEnabled,
 		TwoFactorVerified: *user.TwoFactorVerified,
 		TwoFactorToken:    returnNull(user.TwoFactorToken),
@@ -171,30 +216,100 @@ func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*entities.R
 		Features:          mergedPermissions,
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey,
-		Value: user,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	// no write evicts the entry when one of the user's role windows opens or
+	// closes, so it must not outlive the next one
+	next, err := nextRoleBoundary(r.db.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.redisCache.SetTaggedUntil(ctx, cacheKey, userDTO, next, userCacheTag, roleCacheTag, featureCacheTag); err != nil {
+		return nil, err
+	}
+
+	return r.withOverrides(&userDTO)
+}
+
+// withOverrides applies the user's overrides to the cached role permissions.
+func (r *userRepository) withOverrides(userDTO *entities.ResUserDTO) (*entities.ResUserDTO, error) {
+	overrides, err := userOverrides(r.db, userDTO.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	features, err := applyOverrides(r.db, userDTO.Features, overrides)
+	if err != nil {
 		return nil, err
 	}
 
-	return &userDTO, nil
+	userDTO.Features = features
+	userDTO.PermissionOverrides = overrides
+
+	return userDTO, nil
 }
 
-func (r *userRepository) GetAllWithPage(ctx context.Context, page, size int, roleId, isActive string, phoneNumber string, fullName string) ([]entities.ResAllUserDTOs, int64, error) {
+func (r *userRepository) GetPermissionOverrides(userId uuid.UUID) ([]entities.ResPermissionOverride, error) {
+	return userOverrides(r.db, userId)
+}
+
+// SavePermissionOverride creates the override or replaces the one the user
+// already has on the same feature and action.
+func (r *userRepository) SavePermissionOverride(override *entities.PermissionOverride) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var total int64
+		if err := tx.Model(&entities.Feature{}).Where("id = ?", override.FeatureId).Count(&total).Error; err != nil {
+			return err
+		}
+
+		if total == 0 {
+			return fmt.Errorf("feature not found")
+		}
+
+		if err := checkActionsDeclared(tx, []entities.RoleFeature{{FeatureId: override.FeatureId, Actions: []string{override.Action}}}); err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "feature_id"}, {Name: "action"}},
+			DoUpdates: clause.AssignmentColumns([]string{"effect", "reason", "expires_at", "updated_at", "updated_by"}),
+		}).Create(&override).Error
+	})
+}
+
+func (r *userRepository) DeletePermissionOverride(userId uuid.UUID, id uuid.UUID) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userId).Delete(&entities.PermissionOverride{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("permission override not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) GetAllWithPage(ctx context.Context, pq helpers.PageQuery, roleId, isActive string, phoneNumber string, fullName string) ([]entities.ResAllUserDTOs, int64, string, error) {
 	var users []entities.User
 	var total int64
 	var userDTOs []entities.ResAllUserDTOs
+	var cursor string
+	var cached cachedPage[entities.ResAllUserDTOs]
+
+	cacheKey := listCacheKey(userCacheTag, pq, map[string]string{
+		"roleId":      roleId,
+		"isActive":    strings.ToLower(isActive),
+		"phoneNumber": phoneNumber,
+		"fullName":    strings.ToLower(strings.TrimSpace(fullName)),
+	})
 
-	cacheKey := "users_list"
-
-	offset := (page - 1) * size
+	if err := r.redisCache.Get(ctx, cacheKey, &cached); err == nil {
+		return cached.Items, cached.Total, cached.Cursor, nil
+	}
 
-	query := r.db.Model(&entities.User{}).Preload("Role")
+	query := r.db.WithContext(ctx).Model(&entities.User{}).Preload("Role")
 	if roleId != "" {
-		query = query.Joins("JOIN roles ON roles.id = users.role_id").Where("roles.id = ?", roleId)
+		query = query.Where("users.id IN (?)", r.db.WithContext(ctx).Table(roleAssignments).Select("user_id").Where("role_id = ?", roleId))
 	}
 
 	if isActive != "" {
@@ -206,19 +321,21 @@ func (r *userRepository) GetAllWithPage(ctx context.Context, page, size int, rol
 	}
 
 	if fullName != "" {
-		query.Where("LOWER(CONCAT(first_name,' ',last_name)) LIKE LOWER(?)", "%"+fullName+"%")
+		query = query.Where("LOWER(CONCAT(first_name,' ',last_name)) LIKE LOWER(?)", "%"+fullName+"%")
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	if !pq.UseCursor {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, "", err
+		}
 	}
 
-	if err := r.redisCache.Get(ctx, cacheKey, userDTOs); err == nil {
-		return userDTOs, total, nil
+	if err := query.Scopes(pageScope("users", pq)).Find(&users).Error; err != nil {
+		return nil, 0, "", err
 	}
 
-	if err := query.Limit(size).Offset(offset).Find(&users).Error; err != nil {
-		return nil, 0, err
+	if pq.UseCursor {
+		users, cursor = nextCursor(users, pq.Size, func(u entities.User) uuid.UUID { return u.ID })
 	}
 
 	for _, user := range users {
@@ -233,68 +350,66 @@ func (r *userRepository) GetAllWithPage(ctx context.Context, page, size int, rol
 		})
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey,
-		Value: userDTOs,
-		TTL:   time.Minute * 10,
-	}); err != nil {
-		return nil, 0, err
+	// filtering by role depends on which role windows are open
+	var next *time.Time
+	if roleId != "" {
+		var err error
+		next, err = nextRoleBoundary(r.db.WithContext(ctx))
+		if err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	cached = cachedPage[entities.ResAllUserDTOs]{Items: userDTOs, Total: total, Cursor: cursor}
+	if err := r.redisCache.SetTaggedUntil(ctx, cacheKey, cached, next, userCacheTag, roleCacheTag); err != nil {
+		return nil, 0, "", err
 	}
 
-	return userDTOs, total, nil
+	return userDTOs, total, cursor, nil
 }
 
+// Update writes the set fields of user. Deactivating the last super
+// administrator is refused.
 func (r *userRepository) Update(ctx context.Context, user *entities.User) error {
-	if err := r.db.Where("id=?", user.ID).Updates(&user).Error; err != nil {
-		return err
+	write := func(tx *gorm.DB) error {
+		return tx.Where("id=?", user.ID).Updates(&user).Error
 	}
 
-	cacheKey1 := fmt.Sprintf("user:%s", user.ID)
-	if err := r.redisCache.Delete(ctx, cacheKey1); err != nil {
-		return nil
+	var err error
+	if user.IsActive != nil && !*user.IsActive {
+		err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return keepSuperAdministrator(tx, write)
+		})
+	} else {
+		err = write(r.db.WithContext(ctx))
 	}
-
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey1,
-		Value: user,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	if err != nil {
 		return err
 	}
 
-	//query again
-	cacheKey2 := "users_list"
-	var users []entities.User
-	var userDTOs []entities.ResAllUserDTOs
-
-	if err := r.redisCache.Delete(ctx, cacheKey2); err != nil {
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
 		return err
 	}
 
-	if err := r.db.Preload("Role").Find(&users).Error; err != nil {
-		return err
-	}
+	return nil
+}
 
-	for _, user := range users {
-		userDTOs = append(userDTOs, entities.ResAllUserDTOs{
-			UserID:      user.ID,
-			Email:       user.Email,
-			FullName:    user.FirstName + " " + user.LastName,
-			PhoneNumber: user.PhoneNumber,
-			IsActive:    *user.IsActive,
-			Avatar:      returnNull(user.Avatar),
-			RoleName:    user.Role.Name,
+// UpdateWithRoles is Update followed by SetRoles in one transaction.
+func (r *userRepository) UpdateWithRoles(ctx context.Context, user *entities.User, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return keepSuperAdministrator(tx, func(tx *gorm.DB) error {
+			if err := tx.Where("id=?", user.ID).Updates(user).Error; err != nil {
+				return err
+			}
+
+			return setRoles(tx, user.ID, primary, fallbackId, assignments, user.UpdatedBy)
 		})
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := r.redisCache.Set(&cache.Item{
-		Ctx:   ctx,
-		Key:   cacheKey2,
-		Value: userDTOs,
-		TTL:   time.Minute * 10,
-	}); err != nil {
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
 		return err
 	}
 
@@ -302,13 +417,17 @@ func (r *userRepository) Update(ctx context.Context, user *entities.User) error
 }
 
 func (r *userRepository) Delete(ctx context.Context, id uuid.UUID, deleteBy uuid.UUID) error {
-	err := r.db.Model(&entities.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+	err := r.db.WithContext(ctx).Model(&entities.User{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"deleted_by": deleteBy}).Error
 	if err != nil {
 		return err
 	}
 
-	if err := r.db.Delete(&entities.User{}, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Delete(&entities.User{}, id).Error; err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
 		return err
 	}
 
@@ -367,17 +486,119 @@ func (r *userRepository) IsPhoneExistsForUpdate(phone string, id uuid.UUID) (boo
 	return true, nil
 }
 
+// IsSuperAdministrator reports whether id currently holds the super
+// administrator system role, whatever that role is named.
 func (r *userRepository) IsSuperAdministrator(id uuid.UUID) (bool, error) {
-	var user entities.User
-	if err := r.db.Preload("Role").Where("id=?", id).First(&user).Error; err != nil {
-		return false, nil
+	roles, err := userRoles(r.db, id)
+	if err != nil {
+		return false, err
 	}
 
-	if user.Role.Name == "Super Administrator" {
-		return true, nil
-	} else {
-		return false, nil
+	for _, role := range roles {
+		if role.IsSuperAdministrator() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// countSuperAdministrators counts the active users holding the super
+// administrator role without an end. A window that has not opened yet or
+// that will close does not count.
+func countSuperAdministrators(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Table(roleAssignments).
+		Joins("JOIN users ON users.id = assignments.user_id AND users.deleted_at IS NULL AND users.is_active").
+		Joins("JOIN roles ON roles.id = assignments.role_id AND roles.deleted_at IS NULL").
+		Where("roles.system_key = ? AND assignments.valid_until IS NULL", entities.SystemRoleSuperAdministrator).
+		Distinct("assignments.user_id").
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// lockSuperAdministratorRole takes the row of the super administrator role
+// for update, so that writes which may take the role away from someone run
+// one at a time. It returns false when the role does not exist.
+func lockSuperAdministratorRole(tx *gorm.DB) (bool, error) {
+	var role entities.Role
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("system_key = ?", entities.SystemRoleSuperAdministrator).Limit(1).Find(&role).Error
+	if err != nil {
+		return false, err
+	}
+
+	return role.ID != uuid.Nil, nil
+}
+
+// keepSuperAdministrator runs write and rolls it back when it leaves no
+// active user holding the super administrator role without an end. The
+// count and the write run under the lock of the role row.
+func keepSuperAdministrator(tx *gorm.DB, write func(tx *gorm.DB) error) error {
+	exists, err := lockSuperAdministratorRole(tx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return write(tx)
 	}
+
+	before, err := countSuperAdministrators(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := write(tx); err != nil {
+		return err
+	}
+
+	// before the first super administrator is created there is none to keep
+	if before == 0 {
+		return nil
+	}
+
+	after, err := countSuperAdministrators(tx)
+	if err != nil {
+		return err
+	}
+	if after == 0 {
+		return fmt.Errorf("at least one active user must hold the super administrator role")
+	}
+
+	return nil
+}
+
+// CreateSuperAdministrator creates user, who must hold the super
+// administrator role, unless an active super administrator already exists.
+func (r *userRepository) CreateSuperAdministrator(ctx context.Context, user *entities.User) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := lockSuperAdministratorRole(tx); err != nil {
+			return err
+		}
+
+		count, err := countSuperAdministrators(tx)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("a super administrator already exists")
+		}
+
+		return tx.Create(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (r *userRepository) GetAvatarUserById(id uuid.UUID) (*entities.ResAvatar, error) {
@@ -403,6 +624,186 @@ func (r *userRepository) GetRoleByRoleId(id uuid.UUID) (*entities.Role, error) {
 	return &roleOjb, nil
 }
 
+func (r *userRepository) GetRolesByIds(ids []uuid.UUID) ([]entities.Role, error) {
+	var roles []entities.Role
+	if err := r.db.Where("id IN ?", ids).Order("level DESC, name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *userRepository) GetRoleBySystemKey(key string) (*entities.Role, error) {
+	var role entities.Role
+	if err := r.db.Where("system_key = ?", key).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("system role %s not found", key)
+		}
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// SetRoles replaces every role of userId with assignments, primary becoming
+// the role kept on the user row together with its window and the fallback
+// that takes over once the window ends. Taking the super administrator role
+// from the last user holding it is refused.
+func (r *userRepository) SetRoles(ctx context.Context, userId uuid.UUID, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment, by uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return keepSuperAdministrator(tx, func(tx *gorm.DB) error {
+			return setRoles(tx, userId, primary, fallbackId, assignments, by)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func setRoles(tx *gorm.DB, userId uuid.UUID, primary entities.RoleAssignment, fallbackId *uuid.UUID, assignments []entities.RoleAssignment, by uuid.UUID) error {
+	if err := tx.Where("user_id = ?", userId).Delete(&entities.UserRole{}).Error; err != nil {
+		return err
+	}
+
+	userRoles := make([]entities.UserRole, 0, len(assignments))
+	for _, a := range assignments {
+		if a.RoleId == primary.RoleId {
+			continue
+		}
+		userRoles = append(userRoles, entities.UserRole{UserId: userId, RoleId: a.RoleId, ValidFrom: a.ValidFrom, ValidUntil: a.ValidUntil, CreatedBy: by})
+	}
+
+	if len(userRoles) > 0 {
+		if err := tx.Create(&userRoles).Error; err != nil {
+			return err
+		}
+	}
+
+	var primaryId interface{}
+	if primary.RoleId != uuid.Nil {
+		primaryId = primary.RoleId
+	}
+
+	return tx.Model(&entities.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"role_id":          primaryId,
+		"role_valid_from":  primary.ValidFrom,
+		"role_valid_until": primary.ValidUntil,
+		"fallback_role_id": fallbackId,
+		"updated_by":       by,
+	}).Error
+}
+
+// ExpireRoleAssignments ends every role assignment whose window closed by
+// now: an expired primary role is replaced by the fallback role and expired
+// additional roles are removed. Each transition is written to the audit trail
+// in the same transaction.
+func (r *userRepository) ExpireRoleAssignments(ctx context.Context, now time.Time) ([]entities.RoleExpiry, error) {
+	var expiries []entities.RoleExpiry
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users []entities.User
+		if err := tx.Where("role_valid_until <= ?", now).Find(&users).Error; err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			if err := tx.Model(&entities.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+				"role_id":          user.FallbackRoleId,
+				"role_valid_from":  nil,
+				"role_valid_until": nil,
+				"fallback_role_id": nil,
+			}).Error; err != nil {
+				return err
+			}
+
+			expiry := entities.RoleExpiry{UserId: user.ID, FallbackRoleId: user.FallbackRoleId, ValidUntil: *user.RoleValidUntil}
+			if user.RoleId != nil {
+				expiry.RoleId = *user.RoleId
+			}
+			if err := recordAudit(tx, entities.AuditRoleExpired, "user", user.ID, nil, expiry); err != nil {
+				return err
+			}
+			expiries = append(expiries, expiry)
+		}
+
+		var userRoles []entities.UserRole
+		if err := tx.Where("valid_until <= ?", now).Find(&userRoles).Error; err != nil {
+			return err
+		}
+
+		for _, ur := range userRoles {
+			if err := tx.Where("user_id = ? AND role_id = ?", ur.UserId, ur.RoleId).Delete(&entities.UserRole{}).Error; err != nil {
+				return err
+			}
+
+			expiry := entities.RoleExpiry{UserId: ur.UserId, RoleId: ur.RoleId, ValidUntil: *ur.ValidUntil}
+			if err := recordAudit(tx, entities.AuditRoleAssignmentExpired, "user", ur.UserId, nil, expiry); err != nil {
+				return err
+			}
+			expiries = append(expiries, expiry)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(expiries) > 0 {
+		if err := r.redisCache.Invalidate(ctx, userCacheTag); err != nil {
+			return nil, err
+		}
+	}
+
+	return expiries, nil
+}
+
+// RevokeSessions signs userId out: the current access token is blocked for
+// the rest of its lifetime and the stored tokens are cleared so the refresh
+// token can no longer be used.
+func (r *userRepository) RevokeSessions(ctx context.Context, userId uuid.UUID, actorId *uuid.UUID) error {
+	return revokeSessions(ctx, r.db.WithContext(ctx), r.redisCache, userId, actorId)
+}
+
+func revokeSessions(ctx context.Context, db *gorm.DB, c *taggedCache, userId uuid.UUID, actorId *uuid.UUID) error {
+	var auth entities.Authorization
+	if err := db.Where("user_id = ?", userId).Limit(1).Find(&auth).Error; err != nil {
+		return err
+	}
+
+	if auth.ID == uuid.Nil || (auth.AccessToken == "" && auth.RefreshToken == "") {
+		return nil
+	}
+
+	if auth.AccessToken != "" {
+		if err := c.Set(&cache.Item{
+			Ctx:   ctx,
+			Key:   fmt.Sprintf("blocked:%s", auth.AccessToken),
+			Value: auth.AccessToken,
+			TTL:   helpers.AccessTokenTTL,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entities.Authorization{}).Where("id = ?", auth.ID).Updates(map[string]interface{}{
+			"access_token":  "",
+			"refresh_token": "",
+		}).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, entities.AuditSessionRevoked, "user", userId, actorId, map[string]interface{}{"authorizationId": auth.ID})
+	})
+}
+
 func (r *userRepository) CheckThisUserHaveDataInAuth(userId uuid.UUID) (*entities.Authorization, bool, error) {
 	var auth entities.Authorization
 
@@ -19,7 +19,7 @@ const (
 	dbname   = "mydatabase"
 )
 
-func NewDBServer() *gorm.DB {
+func NewDBServer() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s "+
 		"password=%s dbname=%s sslmode=disable TimeZone=Asia/Bangkok",
 		host, port, user, password, dbname)
@@ -33,15 +33,21 @@ func NewDBServer() *gorm.DB {
 		},
 	)
 
-	var err error
-
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: newLogger,
 		DryRun: false,
 	})
 	if err != nil {
-		panic("failed connect to database")
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := registerQueryMetrics(db); err != nil {
+		log.Printf("failed to register query metrics: %v", err)
+	}
+
+	if err := registerQueryTracing(db); err != nil {
+		log.Printf("failed to register query tracing: %v", err)
 	}
 
-	return db
+	return db, nil
 }
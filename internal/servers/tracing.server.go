@@ -0,0 +1,62 @@
+package servers
+
+import (
+	"work01/internal/helpers"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const querySpanKey = "tracing:query_span"
+
+// registerQueryTracing opens a span for every statement GORM runs, as a child
+// of the span in the statement's context. The SQL is recorded with its
+// placeholders, never with the bound values.
+func registerQueryTracing(db *gorm.DB) error {
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			_, span := helpers.StartSpan(tx.Statement.Context, "gorm."+operation, trace.SpanKindClient,
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperation(operation),
+			)
+			tx.InstanceSet(querySpanKey, span)
+		}
+	}
+
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(querySpanKey)
+		if !ok {
+			return
+		}
+
+		span := v.(trace.Span)
+		span.SetAttributes(
+			semconv.DBSQLTable(tx.Statement.Table),
+			semconv.DBStatement(tx.Statement.SQL.String()),
+		)
+		helpers.EndSpan(span, tx.Error)
+	}
+
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("tracing:before_create", before("create")),
+		callbacks.Create().After("*").Register("tracing:after_create", after),
+		callbacks.Query().Before("*").Register("tracing:before_query", before("query")),
+		callbacks.Query().After("*").Register("tracing:after_query", after),
+		callbacks.Update().Before("*").Register("tracing:before_update", before("update")),
+		callbacks.Update().After("*").Register("tracing:after_update", after),
+		callbacks.Delete().Before("*").Register("tracing:before_delete", before("delete")),
+		callbacks.Delete().After("*").Register("tracing:after_delete", after),
+		callbacks.Row().Before("*").Register("tracing:before_row", before("row")),
+		callbacks.Row().After("*").Register("tracing:after_row", after),
+		callbacks.Raw().Before("*").Register("tracing:before_raw", before("raw")),
+		callbacks.Raw().After("*").Register("tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"work01/internal/entities"
+	"work01/internal/repositories"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	// AdminUsecase holds the operational tasks run from the command line.
+	// There is no acting user, so the access policies are not consulted, but
+	// the rules that keep the system usable, such as always having an active
+	// super administrator, still apply.
+	AdminUsecase interface {
+		CreateSuperAdministrator(ctx context.Context, req entities.ReqUser) (*entities.ResUserDTO, error)
+		ResetPassword(ctx context.Context, email string, password string) (*entities.ResUserDTO, error)
+		SetActive(ctx context.Context, email string, active bool) (*entities.ResUserDTO, error)
+		AssignRoles(ctx context.Context, email string, roleIds []uuid.UUID, fallbackRoleId *uuid.UUID) (*entities.ResUserDTO, error)
+		RevokeSessions(ctx context.Context, email string) (*entities.ResUserDTO, error)
+	}
+
+	adminUsecase struct {
+		repo  repositories.UserRepository
+		users *userUsecase
+	}
+)
+
+func NewAdminUsecase(repo repositories.UserRepository) AdminUsecase {
+	return &adminUsecase{repo: repo, users: &userUsecase{repo: repo}}
+}
+
+// CreateSuperAdministrator creates the first super administrator. It refuses
+// once an active one exists; further admins are created through the API.
+func (s *adminUsecase) CreateSuperAdministrator(ctx context.Context, req entities.ReqUser) (*entities.ResUserDTO, error) {
+	if err := s.users.CheckVariableToCreate(req.FirstName, req.LastName, req.Email, req.PhoneNumber, req.Password, req.ConfirmPassword); err != nil {
+		return nil, err
+	}
+
+	role, err := s.repo.GetRoleBySystemKey(entities.SystemRoleSuperAdministrator)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	active := true
+	id := uuid.New()
+	user := &entities.User{
+		ID:          id,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		PhoneNumber: req.PhoneNumber,
+		Password:    string(hashedPassword),
+		RoleId:      &role.ID,
+		IsActive:    &active,
+		CreatedBy:   id,
+		UpdatedBy:   id,
+	}
+	if err := s.repo.CreateSuperAdministrator(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetById(ctx, id)
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+func (s *adminUsecase) ResetPassword(ctx context.Context, email string, password string) (*entities.ResUserDTO, error) {
+	if err := s.users.CheckVariableChangePassword(password, password); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, &entities.User{ID: user.ID, Password: string(hashedPassword)}); err != nil {
+		return nil, err
+	}
+
+	return s.revoke(ctx, user.ID)
+}
+
+// SetActive unlocks or deactivates an account. A deactivated user is signed
+// out.
+func (s *adminUsecase) SetActive(ctx context.Context, email string, active bool) (*entities.ResUserDTO, error) {
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, &entities.User{ID: user.ID, IsActive: &active}); err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return s.revoke(ctx, user.ID)
+	}
+
+	return s.repo.GetById(ctx, user.ID)
+}
+
+// AssignRoles replaces the roles of a user without an end date. The highest
+// level role becomes the primary one.
+func (s *adminUsecase) AssignRoles(ctx context.Context, email string, roleIds []uuid.UUID, fallbackRoleId *uuid.UUID) (*entities.ResUserDTO, error) {
+	if len(roleIds) == 0 {
+		return nil, fmt.Errorf("at least one role is required")
+	}
+
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.repo.GetRolesByIds(roleIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(uniqueIds(roleIds)) {
+		return nil, fmt.Errorf("role not found")
+	}
+
+	if fallbackRoleId != nil {
+		if _, err := s.repo.GetRoleByRoleId(*fallbackRoleId); err != nil {
+			return nil, fmt.Errorf("fallback role not found")
+		}
+	}
+
+	assignments := make([]entities.RoleAssignment, 0, len(roles))
+	for _, role := range roles {
+		assignments = append(assignments, entities.RoleAssignment{RoleId: role.ID})
+	}
+
+	if err := s.repo.SetRoles(ctx, user.ID, assignments[0], fallbackRoleId, assignments, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	return s.revoke(ctx, user.ID)
+}
+
+func (s *adminUsecase) RevokeSessions(ctx context.Context, email string) (*entities.ResUserDTO, error) {
+	user, err := s.userByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.revoke(ctx, user.ID)
+}
+
+func (s *adminUsecase) revoke(ctx context.Context, userId uuid.UUID) (*entities.ResUserDTO, error) {
+	if err := s.repo.RevokeSessions(ctx, userId, nil); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetById(ctx, userId)
+}
+
+func (s *adminUsecase) userByEmail(email string) (*entities.User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("not found field email")
+	}
+
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return user, nil
+}
+
+func uniqueIds(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
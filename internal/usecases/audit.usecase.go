@@ -0,0 +1,29 @@
+package usecases
+
+import (
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/repositories"
+)
+
+type (
+	AuditUsecase interface {
+		GetAuditLogs(pq helpers.PageQuery, entityType string, entityId string) (helpers.Pagination[entities.AuditLog], error)
+	}
+
+	auditUsecase struct {
+		repo repositories.AuditRepository
+	}
+)
+
+func NewAuditUsecase(repo repositories.AuditRepository) AuditUsecase {
+	return &auditUsecase{repo: repo}
+}
+
+func (s *auditUsecase) GetAuditLogs(pq helpers.PageQuery, entityType string, entityId string) (helpers.Pagination[entities.AuditLog], error) {
+	logs, total, cursor, err := s.repo.GetAll(pq, entityType, entityId)
+	if err != nil {
+		return helpers.Pagination[entities.AuditLog]{}, err
+	}
+	return helpers.NewPagination(pq, total, cursor, logs), nil
+}
@@ -18,7 +18,7 @@ type (
 	AuthorizationUsecase interface {
 		CreateAuthorization(auth entities.Authorization) error
 		GetAuthorizationById(id uuid.UUID) (*entities.Authorization, error)
-		GetAllAuthorizations() ([]entities.Authorization, error)
+		GetAllAuthorizations(pq helpers.PageQuery) (helpers.Pagination[entities.Authorization], error)
 		GetUserDataById(id uuid.UUID) (*entities.ResUserDTO, error)
 		UpdateAuthorization(auth entities.Authorization) error
 		DeleteAuthorization(id uuid.UUID, delBy uuid.UUID) error
@@ -61,12 +61,12 @@ func (s *authorizationUsecase) GetUserDataById(id uuid.UUID) (*entities.ResUserD
 	return user, nil
 }
 
-func (s *authorizationUsecase) GetAllAuthorizations() ([]entities.Authorization, error) {
-	auths, err := s.repo.GetAll()
+func (s *authorizationUsecase) GetAllAuthorizations(pq helpers.PageQuery) (helpers.Pagination[entities.Authorization], error) {
+	auths, total, cursor, err := s.repo.GetAll(pq)
 	if err != nil {
-		return nil, err
+		return helpers.Pagination[entities.Authorization]{}, err
 	}
-	return auths, nil
+	return helpers.NewPagination(pq, total, cursor, auths), nil
 }
 
 func (s *authorizationUsecase) UpdateAuthorization(auth entities.Authorization) error {
@@ -86,6 +86,13 @@ func (s *authorizationUsecase) DeleteAuthorization(id uuid.UUID, delBy uuid.UUID
 }
 
 func (s *authorizationUsecase) Login(identifier, password string) (*entities.User, *entities.AuthToken, error) {
+	user, token, err := s.login(identifier, password)
+	helpers.RecordLogin(err == nil)
+
+	return user, token, err
+}
+
+func (s *authorizationUsecase) login(identifier, password string) (*entities.User, *entities.AuthToken, error) {
 	var user *entities.User
 	var err error
 
@@ -179,6 +186,13 @@ func (s *authorizationUsecase) Logout(id uuid.UUID, tokenString string) error {
 }
 
 func (s *authorizationUsecase) RefreshToken(refreshToken string) (string, error) {
+	accessToken, err := s.refreshToken(refreshToken)
+	helpers.RecordTokenRefresh(err == nil)
+
+	return accessToken, err
+}
+
+func (s *authorizationUsecase) refreshToken(refreshToken string) (string, error) {
 	authorization, err := s.repo.GetAuthorizationByRefreshToken(refreshToken)
 	if err != nil {
 		return "", err
@@ -2,8 +2,10 @@ package usecases
 
 import (
 	"context"
+	"fmt"
 	"mime/multipart"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 	"work01/internal/repositories"
 	"work01/pkg/minio"
 
@@ -12,13 +14,19 @@ import (
 
 type (
 	FeatureUsecase interface {
-		CreateFeature(feature entities.Feature, fileHeader *multipart.FileHeader) error
+		CreateFeature(ctx context.Context, feature entities.Feature, fileHeader *multipart.FileHeader) error
 		GetFeatureById(ctx context.Context, id uuid.UUID) (*entities.FeatureDTO, error)
+		GetFeatureBySlug(ctx context.Context, slug string) (*entities.Feature, error)
 		GetRefFeatures() ([]entities.RefFeatureDTO, error)
 		GetAllFeaturesDefault() ([]entities.Feature, error)
-		GetAllRoleFeatures(ctx context.Context) ([]entities.FeatureDTO, error)
+		GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.FeatureDTO], error)
 		UpdateFeature(ctx context.Context, feature entities.Feature, fileHeader *multipart.FileHeader) error
-		DeleteFeature(id uuid.UUID) error
+		DeleteFeature(ctx context.Context, id uuid.UUID, delBy uuid.UUID, cascade bool) error
+		GetFeatureDeletePreview(id uuid.UUID) (*entities.ResFeatureDeletePreview, error)
+		RestoreFeature(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+		PurgeFeature(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+		MoveFeature(ctx context.Context, id uuid.UUID, req entities.ReqFeatureMove) error
+		ReorderFeatures(ctx context.Context, req entities.ReqFeatureReorder) error
 	}
 
 	featureUsecase struct {
@@ -26,13 +34,45 @@ type (
 	}
 )
 
+const maxMenuDepth = 3
+
 func NewFeatureUsecase(repo repositories.FeatureRepository) FeatureUsecase {
 	return &featureUsecase{repo: repo}
 }
 
-func (s *featureUsecase) CreateFeature(feature entities.Feature, fileHeader *multipart.FileHeader) error {
+func (s *featureUsecase) CreateFeature(ctx context.Context, feature entities.Feature, fileHeader *multipart.FileHeader) error {
+	if err := helpers.ValidateSlug(feature.MenuSlug); err != nil {
+		return err
+	}
+
+	checkSlug, err := s.repo.MenuSlugIsAlreadyExits(feature.MenuSlug)
+	if err != nil {
+		return err
+	}
+	if checkSlug {
+		return fmt.Errorf("the menu slug alredy exists")
+	}
+
+	if err := validateActions(feature.Actions); err != nil {
+		return err
+	}
+
+	if feature.ParentMenuId != nil {
+		if err := s.ValidateParent(feature.ID, *feature.ParentMenuId); err != nil {
+			return err
+		}
+	}
+
+	if feature.MenuSeqNo == 0 {
+		seqNo, err := s.repo.NextSeqNo(feature.ParentMenuId)
+		if err != nil {
+			return err
+		}
+		feature.MenuSeqNo = seqNo
+	}
+
 	if fileHeader != nil {
-		avatarURL, err := minio.UploadAvatar(fileHeader)
+		avatarURL, err := minio.UploadAvatar(ctx, fileHeader)
 		if err != nil {
 			return err
 		}
@@ -54,6 +94,15 @@ func (s *featureUsecase) GetFeatureById(ctx context.Context, id uuid.UUID) (*ent
 	return feature, nil
 }
 
+func (s *featureUsecase) GetFeatureBySlug(ctx context.Context, slug string) (*entities.Feature, error) {
+	feature, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return feature, nil
+}
+
 func (s *featureUsecase) GetRefFeatures() ([]entities.RefFeatureDTO, error) {
 	features, err := s.repo.RefForFeature()
 	if err != nil {
@@ -62,12 +111,12 @@ func (s *featureUsecase) GetRefFeatures() ([]entities.RefFeatureDTO, error) {
 	return features, nil
 }
 
-func (s *featureUsecase) GetAllRoleFeatures(ctx context.Context) ([]entities.FeatureDTO, error) {
-	features, err := s.repo.GetAllRoleFeatures(ctx)
+func (s *featureUsecase) GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.FeatureDTO], error) {
+	features, total, cursor, err := s.repo.GetAllRoleFeatures(ctx, pq)
 	if err != nil {
-		return nil, err
+		return helpers.Pagination[entities.FeatureDTO]{}, err
 	}
-	return features, nil
+	return helpers.NewPagination(pq, total, cursor, features), nil
 }
 
 func (s *featureUsecase) GetAllFeaturesDefault() ([]entities.Feature, error) {
@@ -79,13 +128,43 @@ func (s *featureUsecase) GetAllFeaturesDefault() ([]entities.Feature, error) {
 }
 
 func (s *featureUsecase) UpdateFeature(ctx context.Context, feature entities.Feature, fileHeader *multipart.FileHeader) error {
+	if feature.MenuSlug != "" {
+		if err := helpers.ValidateSlug(feature.MenuSlug); err != nil {
+			return err
+		}
+
+		checkSlug, err := s.repo.MenuSlugIsAlreadyExitsUpdate(feature.ID, feature.MenuSlug)
+		if err != nil {
+			return err
+		}
+		if checkSlug {
+			return fmt.Errorf("the menu slug alredy exists")
+		}
+	}
+
+	if err := validateActions(feature.Actions); err != nil {
+		return err
+	}
+
+	// moving a feature renumbers its old and new siblings, which only Move
+	// does
+	if feature.ParentMenuId != nil {
+		current, err := s.repo.GetDetailById(feature.ID)
+		if err != nil {
+			return fmt.Errorf("feature not found")
+		}
+		if current.ParentMenuId == nil || *current.ParentMenuId != *feature.ParentMenuId {
+			return fmt.Errorf("use the move endpoint to change the parent of a feature")
+		}
+	}
+
 	menuIcon, err := s.repo.GetMenuIconByFeatureId(feature.ID)
 	if err != nil {
 		return err
 	}
 
 	if fileHeader != nil {
-		menuIconURL, err := minio.UploadAvatarUpdate(fileHeader, menuIcon.MenuIcon)
+		menuIconURL, err := minio.UploadAvatarUpdate(ctx, fileHeader, menuIcon.MenuIcon)
 		if err != nil {
 			return err
 		}
@@ -98,10 +177,156 @@ func (s *featureUsecase) UpdateFeature(ctx context.Context, feature entities.Fea
 	return nil
 }
 
-func (s *featureUsecase) DeleteFeature(id uuid.UUID) error {
-	if err := s.repo.Delete(id); err != nil {
+// validateActions checks the actions a feature declares. A nil list means the
+// declaration is left as it is.
+func validateActions(actions []string) error {
+	if actions == nil {
+		return nil
+	}
+
+	if len(actions) == 0 {
+		return fmt.Errorf("a feature must declare at least one action")
+	}
+
+	seen := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		if err := helpers.ValidateAction(action); err != nil {
+			return err
+		}
+
+		if seen[action] {
+			return fmt.Errorf("action %s is declared more than once", action)
+		}
+		seen[action] = true
+	}
+
+	return nil
+}
+
+// DeleteFeature refuses to remove a feature that roles or child menus still
+// depend on unless cascade is set, in which case the whole subtree goes. The
+// menu icons stay in MinIO until the feature is purged, so a restored feature
+// gets its icon back.
+func (s *featureUsecase) DeleteFeature(ctx context.Context, id uuid.UUID, delBy uuid.UUID, cascade bool) error {
+	if err := s.repo.Delete(ctx, id, delBy, cascade); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *featureUsecase) GetFeatureDeletePreview(id uuid.UUID) (*entities.ResFeatureDeletePreview, error) {
+	feature, err := s.repo.GetDetailById(id)
+	if err != nil {
+		return nil, fmt.Errorf("feature not found")
+	}
+
+	descendants, err := s.repo.GetDescendants(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uuid.UUID{id}
+	descendantRefs := []entities.FeatureRef{}
+	for _, d := range descendants {
+		ids = append(ids, d.ID)
+		descendantRefs = append(descendantRefs, entities.FeatureRef{FeatureId: d.ID, Name: d.Name})
+	}
+
+	roles, err := s.repo.GetRolesUsingFeatures(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIds := make([]uuid.UUID, 0, len(roles))
+	roleRefs := []entities.ResAllRoleDropDown{}
+	for _, role := range roles {
+		roleIds = append(roleIds, role.ID)
+		roleRefs = append(roleRefs, entities.ResAllRoleDropDown{RoleID: role.ID, RoleName: role.Name})
+	}
+
+	numberUser, err := s.repo.CountUsersWithRoles(roleIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.ResFeatureDeletePreview{
+		FeatureId:   feature.ID,
+		FeatureName: feature.Name,
+		CanDelete:   len(descendants) == 0 && len(roles) == 0,
+		Descendants: descendantRefs,
+		Roles:       roleRefs,
+		NumberUser:  numberUser,
+	}, nil
+}
+
+func (s *featureUsecase) RestoreFeature(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	if _, err := s.repo.GetDeletedById(id); err != nil {
+		return nil, fmt.Errorf("deleted feature not found")
+	}
+
+	// the parent and slug checks run inside Restore's transaction
+	ids, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// PurgeFeature removes a deleted feature and the subtree deleted with it for
+// good, menu icons included.
+func (s *featureUsecase) PurgeFeature(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	features, err := s.repo.Purge(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(features))
+	var iconErr error
+	for _, f := range features {
+		ids = append(ids, f.ID)
+		if f.MenuIcon == "" {
+			continue
+		}
+		if err := minio.RemoveFile(ctx, f.MenuIcon); err != nil && iconErr == nil {
+			iconErr = fmt.Errorf("feature is purged but its menu icon could not be removed: %s", err.Error())
+		}
+	}
+
+	return ids, iconErr
+}
+
+func (s *featureUsecase) MoveFeature(ctx context.Context, id uuid.UUID, req entities.ReqFeatureMove) error {
+	if _, err := s.repo.GetDetailById(id); err != nil {
+		return fmt.Errorf("feature not found")
+	}
+
+	if req.Position < 0 {
+		return fmt.Errorf("position can not be negative")
+	}
+
+	// the parent is checked by Move under the tree lock
+	if err := s.repo.Move(ctx, id, req.ParentMenuId, req.Position, maxMenuDepth); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReorderFeatures checks that req lists every child of the parent exactly
+// once inside the repository's transaction.
+func (s *featureUsecase) ReorderFeatures(ctx context.Context, req entities.ReqFeatureReorder) error {
+	if err := s.repo.Reorder(ctx, req.ParentMenuId, req.FeatureIds); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ValidateParent checks that parentId exists, is not featureId or one of its
+// descendants, and that hanging the subtree of featureId below it stays
+// within maxMenuDepth.
+func (s *featureUsecase) ValidateParent(featureId uuid.UUID, parentId uuid.UUID) error {
+	return s.repo.CheckParent(featureId, parentId, maxMenuDepth)
+}
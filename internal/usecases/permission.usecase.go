@@ -0,0 +1,216 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type (
+	PermissionUsecase interface {
+		GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]entities.FeatureDTODetails, error)
+		GetUserPermissionBySlug(ctx context.Context, userId uuid.UUID, slug string) (*entities.FeatureDTODetails, error)
+		HasPermission(ctx context.Context, userId uuid.UUID, slug string, action string) (bool, error)
+		CheckPermissions(ctx context.Context, userId uuid.UUID, checks []entities.PermissionCheck) ([]entities.PermissionDecision, error)
+		CheckPermissionsFor(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, checks []entities.PermissionCheck) ([]entities.PermissionDecision, error)
+		GetUserMenu(ctx context.Context, userId uuid.UUID, lang string) ([]entities.MenuItem, error)
+		GetUserMenuFor(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, lang string) ([]entities.MenuItem, error)
+	}
+
+	permissionUsecase struct {
+		repo   repositories.PermissionRepository
+		policy PolicyUsecase
+	}
+)
+
+// maxPermissionChecks bounds the number of checks in one request.
+const maxPermissionChecks = 100
+
+func NewPermissionUsecase(repo repositories.PermissionRepository, policy PolicyUsecase) PermissionUsecase {
+	return &permissionUsecase{repo: repo, policy: policy}
+}
+
+func (s *permissionUsecase) GetUserPermissions(ctx context.Context, userId uuid.UUID) ([]entities.FeatureDTODetails, error) {
+	permissions, err := s.repo.GetUserPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// GetUserPermissionBySlug returns nil when the user's role has no entry for
+// the feature.
+func (s *permissionUsecase) GetUserPermissionBySlug(ctx context.Context, userId uuid.UUID, slug string) (*entities.FeatureDTODetails, error) {
+	permissions, err := s.repo.GetUserPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, permission := range permissions {
+		if permission.MenuSlug == slug {
+			return &permission, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// HasPermission identifies the feature by slug rather than id, so checks
+// keep working across environments where the feature rows were created
+// independently.
+func (s *permissionUsecase) HasPermission(ctx context.Context, userId uuid.UUID, slug string, action string) (bool, error) {
+	permission, err := s.GetUserPermissionBySlug(ctx, userId, slug)
+	if err != nil {
+		return false, err
+	}
+
+	allowed, _ := entities.DecidePermission(permission, action)
+	return allowed, nil
+}
+
+// CheckPermissions answers every check for userId with the same evaluator as
+// HasPermission, loading the user's permissions once.
+func (s *permissionUsecase) CheckPermissions(ctx context.Context, userId uuid.UUID, checks []entities.PermissionCheck) ([]entities.PermissionDecision, error) {
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("at least one permission check is required")
+	}
+	if len(checks) > maxPermissionChecks {
+		return nil, fmt.Errorf("at most %d permission checks are allowed per request", maxPermissionChecks)
+	}
+	for _, check := range checks {
+		if check.Feature == "" || check.Action == "" {
+			return nil, fmt.Errorf("every permission check needs a feature and an action")
+		}
+	}
+
+	permissions, err := s.repo.GetUserPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	bySlug := make(map[string]*entities.FeatureDTODetails, len(permissions))
+	for i := range permissions {
+		bySlug[permissions[i].MenuSlug] = &permissions[i]
+	}
+
+	decisions := make([]entities.PermissionDecision, 0, len(checks))
+	for _, check := range checks {
+		allowed, reason := entities.DecidePermission(bySlug[check.Feature], check.Action)
+		decisions = append(decisions, entities.PermissionDecision{
+			Feature: check.Feature,
+			Action:  check.Action,
+			Allowed: allowed,
+			Reason:  reason,
+		})
+	}
+
+	return decisions, nil
+}
+
+// CheckPermissionsFor runs the checks for userId on behalf of actorId, who
+// needs the access policies to allow it when asking about someone else.
+func (s *permissionUsecase) CheckPermissionsFor(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, checks []entities.PermissionCheck) ([]entities.PermissionDecision, error) {
+	if err := s.canCheck(actorId, userId); err != nil {
+		return nil, err
+	}
+
+	return s.CheckPermissions(ctx, userId, checks)
+}
+
+// canCheck lets anyone look at their own permissions and asks the access
+// policies about anyone else's.
+func (s *permissionUsecase) canCheck(actorId uuid.UUID, userId uuid.UUID) error {
+	if actorId == userId {
+		return nil
+	}
+
+	decision, err := s.policy.AuthorizeUser(actorId, entities.ActionUserCheck, userId)
+	if err != nil {
+		return err
+	}
+
+	return helpers.Mark(denied(decision, "you do not have permission to check the permissions of this user"), helpers.ErrForbidden)
+}
+
+// GetUserMenu nests the caller's viewable, active features under their
+// parents. A feature whose parent is not visible is dropped with it, so the
+// tree never shows entries the user can not navigate to.
+func (s *permissionUsecase) GetUserMenu(ctx context.Context, userId uuid.UUID, lang string) ([]entities.MenuItem, error) {
+	permissions, err := s.repo.GetUserPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[uuid.UUID]entities.FeatureDTODetails)
+	for _, permission := range permissions {
+		if permission.IsActive != nil && *permission.IsActive && permission.Allows(entities.PermissionView) {
+			visible[permission.ID] = permission
+		}
+	}
+
+	children := make(map[uuid.UUID][]entities.FeatureDTODetails)
+	var roots []entities.FeatureDTODetails
+	for _, feature := range visible {
+		if feature.ParentMenuId == nil {
+			roots = append(roots, feature)
+			continue
+		}
+
+		if _, ok := visible[*feature.ParentMenuId]; ok {
+			children[*feature.ParentMenuId] = append(children[*feature.ParentMenuId], feature)
+		}
+	}
+
+	return buildMenu(roots, children, lang), nil
+}
+
+// GetUserMenuFor is GetUserMenu for userId on behalf of actorId, who needs
+// the same access policy as for checking that user's permissions.
+func (s *permissionUsecase) GetUserMenuFor(ctx context.Context, actorId uuid.UUID, userId uuid.UUID, lang string) ([]entities.MenuItem, error) {
+	if err := s.canCheck(actorId, userId); err != nil {
+		return nil, err
+	}
+
+	return s.GetUserMenu(ctx, userId, lang)
+}
+
+// buildMenu orders siblings the way the feature tree stores them: by
+// sequence number, then name, then id, so the order does not depend on map
+// iteration.
+func buildMenu(features []entities.FeatureDTODetails, children map[uuid.UUID][]entities.FeatureDTODetails, lang string) []entities.MenuItem {
+	sort.Slice(features, func(i, j int) bool {
+		a, b := features[i], features[j]
+		if a.MenuSeqNo != b.MenuSeqNo {
+			return a.MenuSeqNo < b.MenuSeqNo
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) < 0
+	})
+
+	menu := make([]entities.MenuItem, 0, len(features))
+	for _, feature := range features {
+		menu = append(menu, entities.MenuItem{
+			FeatureId: feature.ID,
+			Name:      menuName(feature, lang),
+			MenuSlug:  feature.MenuSlug,
+			MenuIcon:  feature.MenuIcon,
+			MenuSeqNo: feature.MenuSeqNo,
+			Children:  buildMenu(children[feature.ID], children, lang),
+		})
+	}
+
+	return menu
+}
+
+func menuName(feature entities.FeatureDTODetails, lang string) string {
+	return helpers.FeatureName(helpers.ParseLanguage(lang), feature.MenuSlug, feature.MenuNameTh, feature.MenuNameEn, feature.Name)
+}
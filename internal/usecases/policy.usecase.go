@@ -0,0 +1,362 @@
+package usecases
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"work01/internal/entities"
+	"work01/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// PolicyUsecase is the single place authorization decisions between
+	// users are made. It evaluates the default policies together with the
+	// stored ones against the attributes of the acting user and the resource.
+	PolicyUsecase interface {
+		AuthorizeUser(actorId uuid.UUID, action string, userId uuid.UUID) (entities.PolicyDecision, error)
+		AuthorizeRole(actorId uuid.UUID, action string, role entities.Role) (entities.PolicyDecision, error)
+		Authorize(actorId uuid.UUID, resource string, action string) (entities.PolicyDecision, error)
+		GetPolicies() ([]entities.AccessPolicy, error)
+		CreatePolicy(req entities.ReqAccessPolicy, creBy uuid.UUID) (*entities.AccessPolicy, error)
+		UpdatePolicy(id uuid.UUID, req entities.ReqAccessPolicy, updBy uuid.UUID) (*entities.AccessPolicy, error)
+		DeletePolicy(id uuid.UUID, delBy uuid.UUID) error
+		GetOrgUnits() ([]entities.OrgUnit, error)
+		GetOrgUnitById(id uuid.UUID) (*entities.OrgUnit, error)
+		CreateOrgUnit(req entities.ReqOrgUnit, creBy uuid.UUID) (*entities.OrgUnit, error)
+		UpdateOrgUnit(id uuid.UUID, req entities.ReqOrgUnit, updBy uuid.UUID) (*entities.OrgUnit, error)
+		DeleteOrgUnit(id uuid.UUID, delBy uuid.UUID) error
+	}
+
+	policyUsecase struct {
+		repo repositories.PolicyRepository
+	}
+)
+
+func NewPolicyUsecase(repo repositories.PolicyRepository) PolicyUsecase {
+	return &policyUsecase{repo: repo}
+}
+
+// AuthorizeUser decides whether actorId may perform action on userId.
+func (s *policyUsecase) AuthorizeUser(actorId uuid.UUID, action string, userId uuid.UUID) (entities.PolicyDecision, error) {
+	attrs, err := s.subject(actorId)
+	if err != nil {
+		return entities.PolicyDecision{}, err
+	}
+
+	target, err := s.repo.GetUserAttributes(userId)
+	if err != nil {
+		return entities.PolicyDecision{}, err
+	}
+	attrs.Set("resource", *target)
+
+	return s.evaluate(entities.ResourceUser, action, attrs)
+}
+
+// AuthorizeRole decides whether actorId may perform action on role. For
+// create and level changes role carries the requested level.
+func (s *policyUsecase) AuthorizeRole(actorId uuid.UUID, action string, role entities.Role) (entities.PolicyDecision, error) {
+	attrs, err := s.subject(actorId)
+	if err != nil {
+		return entities.PolicyDecision{}, err
+	}
+	attrs.SetRole(role)
+
+	return s.evaluate(entities.ResourceRole, action, attrs)
+}
+
+// Authorize decides on actions that have no particular resource instance,
+// such as managing policies or org units.
+func (s *policyUsecase) Authorize(actorId uuid.UUID, resource string, action string) (entities.PolicyDecision, error) {
+	attrs, err := s.subject(actorId)
+	if err != nil {
+		return entities.PolicyDecision{}, err
+	}
+
+	return s.evaluate(resource, action, attrs)
+}
+
+func (s *policyUsecase) subject(actorId uuid.UUID) (entities.Attributes, error) {
+	actor, err := s.repo.GetUserAttributes(actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := entities.Attributes{}
+	attrs.Set("subject", *actor)
+
+	return attrs, nil
+}
+
+func (s *policyUsecase) evaluate(resource string, action string, attrs entities.Attributes) (entities.PolicyDecision, error) {
+	stored, err := s.repo.GetPolicies(resource, action)
+	if err != nil {
+		return entities.PolicyDecision{}, err
+	}
+
+	policies := make([]entities.AccessPolicy, 0, len(stored)+len(entities.DefaultAccessPolicies))
+	for _, p := range entities.DefaultAccessPolicies {
+		if p.Resource == resource && p.Action == action {
+			policies = append(policies, p)
+		}
+	}
+	policies = append(policies, stored...)
+
+	return entities.EvaluatePolicies(policies, attrs), nil
+}
+
+// denied turns a negative decision into an error, using the message of the
+// deciding policy when it has one.
+func denied(decision entities.PolicyDecision, message string) error {
+	if decision.Allowed {
+		return nil
+	}
+
+	if decision.Message != "" {
+		return fmt.Errorf("%s", decision.Message)
+	}
+
+	return fmt.Errorf("%s", message)
+}
+
+func (s *policyUsecase) canManage(actorId uuid.UUID, resource string) error {
+	decision, err := s.Authorize(actorId, resource, entities.ActionManage)
+	if err != nil {
+		return err
+	}
+
+	if resource == entities.ResourceOrgUnit {
+		return denied(decision, "you do not have permission to manage org units")
+	}
+	return denied(decision, "you do not have permission to manage access policies")
+}
+
+// GetPolicies returns the default policies followed by the stored ones.
+func (s *policyUsecase) GetPolicies() ([]entities.AccessPolicy, error) {
+	stored, err := s.repo.GetAllPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]entities.AccessPolicy{}, entities.DefaultAccessPolicies...), stored...), nil
+}
+
+func (s *policyUsecase) CreatePolicy(req entities.ReqAccessPolicy, creBy uuid.UUID) (*entities.AccessPolicy, error) {
+	if err := s.canManage(creBy, entities.ResourcePolicy); err != nil {
+		return nil, err
+	}
+
+	policy := entities.AccessPolicy{ID: uuid.New(), CreatedBy: creBy, UpdatedBy: creBy}
+	if err := s.applyPolicy(&policy, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreatePolicy(&policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+func (s *policyUsecase) UpdatePolicy(id uuid.UUID, req entities.ReqAccessPolicy, updBy uuid.UUID) (*entities.AccessPolicy, error) {
+	if err := s.canManage(updBy, entities.ResourcePolicy); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.repo.GetPolicyById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.UpdatedBy = updBy
+	if err := s.applyPolicy(policy, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdatePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (s *policyUsecase) DeletePolicy(id uuid.UUID, delBy uuid.UUID) error {
+	if err := s.canManage(delBy, entities.ResourcePolicy); err != nil {
+		return err
+	}
+
+	return s.repo.DeletePolicy(id, delBy)
+}
+
+// applyPolicy validates req and copies it onto policy.
+func (s *policyUsecase) applyPolicy(policy *entities.AccessPolicy, req entities.ReqAccessPolicy) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("policy name is required")
+	}
+
+	for _, p := range entities.DefaultAccessPolicies {
+		if p.Name == req.Name {
+			return fmt.Errorf("the policy name already exists")
+		}
+	}
+
+	exists, err := s.repo.PolicyNameExists(req.Name, policy.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the policy name already exists")
+	}
+
+	actions, ok := entities.PolicyActions[req.Resource]
+	if !ok {
+		return fmt.Errorf("invalid policy resource: %s", req.Resource)
+	}
+
+	validAction := false
+	for _, a := range actions {
+		if a == req.Action {
+			validAction = true
+		}
+	}
+	if !validAction {
+		return fmt.Errorf("invalid policy action: %s", req.Action)
+	}
+
+	if req.Effect != entities.PolicyAllow && req.Effect != entities.PolicyDeny {
+		return fmt.Errorf("effect must be allow or deny")
+	}
+
+	if len(req.Conditions) == 0 {
+		return fmt.Errorf("a policy needs at least one condition")
+	}
+	for _, c := range req.Conditions {
+		if err := validateCondition(c); err != nil {
+			return err
+		}
+	}
+
+	policy.Name = req.Name
+	policy.Description = req.Description
+	policy.Resource = req.Resource
+	policy.Action = req.Action
+	policy.Effect = req.Effect
+	policy.Conditions = req.Conditions
+	policy.Message = req.Message
+	policy.IsActive = req.IsActive
+	if policy.IsActive == nil {
+		active := true
+		policy.IsActive = &active
+	}
+
+	return nil
+}
+
+func validateCondition(c entities.PolicyCondition) error {
+	if !strings.HasPrefix(c.Attribute, "subject.") && !strings.HasPrefix(c.Attribute, "resource.") {
+		return fmt.Errorf("condition attribute must start with subject or resource")
+	}
+
+	switch c.Operator {
+	case entities.OpEq, entities.OpNe, entities.OpIn, entities.OpContains:
+	case entities.OpGt, entities.OpGte, entities.OpLt, entities.OpLte:
+		if !strings.HasPrefix(c.Value, "subject.") && !strings.HasPrefix(c.Value, "resource.") {
+			if _, err := strconv.ParseFloat(c.Value, 64); err != nil {
+				return fmt.Errorf("condition value must be a number for operator %s", c.Operator)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid condition operator: %s", c.Operator)
+	}
+
+	if c.Value == "" {
+		return fmt.Errorf("condition value is required")
+	}
+
+	return nil
+}
+
+func (s *policyUsecase) GetOrgUnits() ([]entities.OrgUnit, error) {
+	return s.repo.GetAllOrgUnits()
+}
+
+func (s *policyUsecase) GetOrgUnitById(id uuid.UUID) (*entities.OrgUnit, error) {
+	return s.repo.GetOrgUnitById(id)
+}
+
+func (s *policyUsecase) CreateOrgUnit(req entities.ReqOrgUnit, creBy uuid.UUID) (*entities.OrgUnit, error) {
+	if err := s.canManage(creBy, entities.ResourceOrgUnit); err != nil {
+		return nil, err
+	}
+
+	unit := entities.OrgUnit{ID: uuid.New(), CreatedBy: creBy, UpdatedBy: creBy}
+	if err := s.applyOrgUnit(&unit, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateOrgUnit(&unit); err != nil {
+		return nil, err
+	}
+
+	return &unit, nil
+}
+
+func (s *policyUsecase) UpdateOrgUnit(id uuid.UUID, req entities.ReqOrgUnit, updBy uuid.UUID) (*entities.OrgUnit, error) {
+	if err := s.canManage(updBy, entities.ResourceOrgUnit); err != nil {
+		return nil, err
+	}
+
+	unit, err := s.repo.GetOrgUnitById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	unit.UpdatedBy = updBy
+	if err := s.applyOrgUnit(unit, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateOrgUnit(unit); err != nil {
+		return nil, err
+	}
+
+	return unit, nil
+}
+
+func (s *policyUsecase) DeleteOrgUnit(id uuid.UUID, delBy uuid.UUID) error {
+	if err := s.canManage(delBy, entities.ResourceOrgUnit); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteOrgUnit(id, delBy)
+}
+
+// applyOrgUnit validates req and copies it onto unit. The parent must exist
+// and must not be the unit itself or one of its descendants.
+func (s *policyUsecase) applyOrgUnit(unit *entities.OrgUnit, req entities.ReqOrgUnit) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("org unit name is required")
+	}
+
+	if req.ParentId != nil {
+		if _, err := s.repo.GetOrgUnitById(*req.ParentId); err != nil {
+			return err
+		}
+
+		path, err := s.repo.GetOrgUnitPath(*req.ParentId)
+		if err != nil {
+			return err
+		}
+		if containsId(path, unit.ID) {
+			return fmt.Errorf("an org unit can not be moved under itself")
+		}
+	}
+
+	unit.Name = req.Name
+	unit.ParentId = req.ParentId
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 	"work01/internal/repositories"
 
 	"github.com/google/uuid"
@@ -14,19 +15,25 @@ type (
 		CreateRole(role entities.Role, roleFeatures []entities.RoleFeature) error
 		GetRoleById(ctx context.Context, id uuid.UUID) (*entities.ResRoleDetails, error)
 		GetAllRolesDefault() ([]entities.Role, error)
-		GetAllRolesModify(ctx context.Context) ([]entities.ResAllRoleDetails, error)
+		GetAllRolesModify(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.ResAllRoleDetails], error)
 		GetAllRolesDropdown(ctx context.Context) ([]entities.ResAllRoleDropDown, error)
-		UpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) error
-		DeleteRole(ctx context.Context, id uuid.UUID, delBy uuid.UUID) error
+		UpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) (*entities.ResRoleDetails, error)
+		PreviewUpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) (*entities.ResRoleChangePreview, error)
+		DeleteRole(ctx context.Context, id uuid.UUID, reassignTo *uuid.UUID, delBy uuid.UUID) (*entities.ResRoleReassignment, error)
+		CloneRole(ctx context.Context, sourceId uuid.UUID, req entities.ReqRoleClone, creBy uuid.UUID) (*entities.ResRoleDetails, error)
+		GetPermissionTemplates() ([]entities.PermissionTemplate, error)
+		CreatePermissionTemplate(template entities.PermissionTemplate) error
+		ApplyPermissionTemplate(ctx context.Context, roleId uuid.UUID, req entities.ReqApplyTemplate, manageBy uuid.UUID) (*entities.ResRoleDetails, error)
 	}
 
 	roleUsecase struct {
-		repo repositories.RoleRepository
+		repo   repositories.RoleRepository
+		policy PolicyUsecase
 	}
 )
 
-func NewRoleUsecase(repo repositories.RoleRepository) RoleUsecase {
-	return &roleUsecase{repo: repo}
+func NewRoleUsecase(repo repositories.RoleRepository, policy PolicyUsecase) RoleUsecase {
+	return &roleUsecase{repo: repo, policy: policy}
 }
 
 func (s *roleUsecase) CreateRole(role entities.Role, roleFeatures []entities.RoleFeature) error {
@@ -54,6 +61,7 @@ func (s *roleUsecase) GetRoleById(ctx context.Context, id uuid.UUID) (*entities.
 		RoleID:    role.ID,
 		RoleLevel: role.Level,
 		RoleName:  role.Name,
+		IsSystem:  role.IsSystem(),
 		Features:  roleFeatures,
 	}
 
@@ -69,17 +77,17 @@ func (s *roleUsecase) GetAllRolesDefault() ([]entities.Role, error) {
 	return roles, nil
 }
 
-func (s *roleUsecase) GetAllRolesModify(ctx context.Context) ([]entities.ResAllRoleDetails, error) {
-	roles, err := s.repo.GetAllModify(ctx)
+func (s *roleUsecase) GetAllRolesModify(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.ResAllRoleDetails], error) {
+	roles, total, cursor, err := s.repo.GetAllModify(ctx, pq)
 	if err != nil {
-		return nil, err
+		return helpers.Pagination[entities.ResAllRoleDetails]{}, err
 	}
 
-	return roles, nil
+	return helpers.NewPagination(pq, total, cursor, roles), nil
 }
 
 func (s *roleUsecase) GetAllRolesDropdown(ctx context.Context) ([]entities.ResAllRoleDropDown, error) {
-	roles, err := s.repo.GetAllModify(ctx)
+	roles, err := s.repo.GetAllDefault()
 	if err != nil {
 		return nil, err
 	}
@@ -87,67 +95,325 @@ func (s *roleUsecase) GetAllRolesDropdown(ctx context.Context) ([]entities.ResAl
 	var roleRes []entities.ResAllRoleDropDown
 	for _, role := range roles {
 		roleRes = append(roleRes, entities.ResAllRoleDropDown{
-			RoleID:   role.RoleID,
-			RoleName: role.RoleName,
+			RoleID:   role.ID,
+			RoleName: role.Name,
 		})
 	}
 
 	return roleRes, nil
 }
 
-func (s *roleUsecase) UpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) error {
+// UpdateRole replaces the role's permission matrix with roleFeatures when it
+// is not nil and returns the matrix as stored.
+func (s *roleUsecase) UpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) (*entities.ResRoleDetails, error) {
 	if role.Name == "" {
-		return fmt.Errorf("role name cannot be empty on update")
+		return nil, helpers.Mark(fmt.Errorf("role name cannot be empty on update"), helpers.ErrInvalid)
 	}
 
 	roleSelect, _, err := s.repo.GetById(ctx, role.ID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if violations := systemRoleViolations(*roleSelect, *role); len(violations) > 0 {
+		return nil, helpers.Mark(fmt.Errorf("%s", violations[0]), helpers.ErrInvalid)
+	}
+
+	if err := s.ValidateUpdateBodyRole(role.ID, roleSelect.Level, role.Name, role.Level, role.UpdatedBy); err != nil {
+		return nil, err
 	}
 
-	userRoleLevel, err := s.repo.GetRoleLevelOfRoleUserByUserId(role.UpdatedBy)
+	matrix, err := s.repo.Update(ctx, role, roleFeatures)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if role.Level > userRoleLevel.RoleLevel {
-		return fmt.Errorf("you can not modify your role level to higher than tour level")
+	res := entities.ResRoleDetails{
+		RoleID:    role.ID,
+		RoleLevel: role.Level,
+		RoleName:  role.Name,
+		IsSystem:  roleSelect.IsSystem(),
+		Features:  matrix,
 	}
 
-	if err := s.ValidateUpdateBodyRole(role.ID, role.Level, role.Name, roleSelect.Level, role.UpdatedBy); err != nil {
-		return err
+	return &res, nil
+}
+
+// PreviewUpdateRole is a dry run of UpdateRole: it reports what the update
+// would change and for whom, and every reason it would be rejected, without
+// saving anything. A caller who may not manage the role at all is refused
+// instead, since the preview lists the role's users.
+func (s *roleUsecase) PreviewUpdateRole(ctx context.Context, role *entities.Role, roleFeatures []entities.RoleFeature) (*entities.ResRoleChangePreview, error) {
+	roleSelect, _, err := s.repo.GetById(ctx, role.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	err = s.repo.Update(ctx, role, roleFeatures)
+	if err := s.authorize(role.UpdatedBy, entities.ActionRoleManage, *roleSelect, "the role level you hold must be higher than the role level you are attempting to manage"); err != nil {
+		return nil, err
+	}
+
+	violations := []string{}
+	if role.Name == "" {
+		violations = append(violations, "role name cannot be empty on update")
+	}
+
+	violations = append(violations, systemRoleViolations(*roleSelect, *role)...)
+
+	found, err := s.updateViolations(role.ID, roleSelect.Level, role.Name, role.Level, role.UpdatedBy)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, v := range found {
+		violations = append(violations, v.Error())
 	}
 
-	return nil
+	res := entities.ResRoleChangePreview{
+		RoleID:        role.ID,
+		RoleName:      role.Name,
+		RoleLevel:     role.Level,
+		Changes:       []entities.FeatureChange{},
+		AffectedUsers: []entities.AffectedUser{},
+	}
+
+	changes, users, err := s.repo.PreviewUpdate(ctx, role.ID, roleFeatures)
+	if err != nil {
+		violations = append(violations, err.Error())
+	} else {
+		res.Changes = changes
+		if roleSelect.IsSystem() && revokesAny(changes) {
+			violations = append(violations, "can not remove permissions from a system role")
+		}
+		if users != nil {
+			res.AffectedUsers = users
+		}
+	}
+
+	res.AffectedUserCount = len(res.AffectedUsers)
+	res.Violations = violations
+	res.CanSave = len(violations) == 0
+
+	return &res, nil
+}
+
+// systemRoleViolations lists why update may not be applied to current when
+// current is a system role. Permission reductions are refused by the
+// repository, where the stored matrix is known.
+func systemRoleViolations(current entities.Role, update entities.Role) []string {
+	if !current.IsSystem() {
+		return nil
+	}
+
+	var violations []string
+	if update.Name != current.Name {
+		violations = append(violations, "can not rename a system role")
+	}
+	if update.Level < current.Level {
+		violations = append(violations, "can not lower the level of a system role")
+	}
+
+	return violations
+}
+
+func revokesAny(changes []entities.FeatureChange) bool {
+	for _, c := range changes {
+		if len(c.Revoked) > 0 {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (s *roleUsecase) DeleteRole(ctx context.Context, id uuid.UUID, delBy uuid.UUID) error {
+// DeleteRole deletes a role. A role that is still held by users can only be
+// deleted when reassignTo names the role they are moved to; the reassignment
+// is returned in that case.
+func (s *roleUsecase) DeleteRole(ctx context.Context, id uuid.UUID, reassignTo *uuid.UUID, delBy uuid.UUID) (*entities.ResRoleReassignment, error) {
 	role, _, err := s.repo.GetById(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if role.IsSystem() {
+		return nil, fmt.Errorf("can not delete a system role")
+	}
+
+	inUse, err := s.ValidateBodyRoleDelete(id, role.Level, reassignTo, delBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if inUse {
+		if err := s.validateReassignment(ctx, id, *reassignTo, delBy); err != nil {
+			return nil, err
+		}
+
+		return s.repo.ReassignAndDelete(ctx, id, *reassignTo, delBy)
+	}
+
+	err = s.repo.Delete(ctx, id, delBy)
+	if err != nil {
+		return nil, err
 	}
 
-	if role.Name == "Super Administrator" {
-		return fmt.Errorf("can't remove role super administrator")
+	return nil, nil
+}
+
+// validateReassignment checks that the users of roleId can be moved to
+// toRoleId by manageBy.
+func (s *roleUsecase) validateReassignment(ctx context.Context, roleId uuid.UUID, toRoleId uuid.UUID, manageBy uuid.UUID) error {
+	if toRoleId == roleId {
+		return fmt.Errorf("can not reassign users to the role being deleted")
 	}
 
-	if err := s.ValidateBodyRoleDelete(id, role.Level, delBy); err != nil {
+	target, _, err := s.repo.GetById(ctx, toRoleId)
+	if err != nil {
 		return err
 	}
 
-	err = s.repo.Delete(id, delBy)
+	return s.authorize(manageBy, entities.ActionRoleAssign, *target, "the role level you hold must be higher than the role level you are attempting to reassign users to")
+}
+
+// CloneRole copies the permission matrix of sourceId into a new role, which
+// is validated like any other role the caller creates.
+func (s *roleUsecase) CloneRole(ctx context.Context, sourceId uuid.UUID, req entities.ReqRoleClone, creBy uuid.UUID) (*entities.ResRoleDetails, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("role name cannot be empty on create")
+	}
+
+	if _, _, err := s.repo.GetById(ctx, sourceId); err != nil {
+		return nil, err
+	}
+
+	if err := s.ValidateBodyRole(req.Name, req.Level, creBy); err != nil {
+		return nil, err
+	}
+
+	role := entities.Role{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Level:     req.Level,
+		CreatedBy: creBy,
+	}
+
+	matrix, err := s.repo.Clone(ctx, sourceId, &role)
+	if err != nil {
+		return nil, err
+	}
+
+	res := entities.ResRoleDetails{
+		RoleID:    role.ID,
+		RoleLevel: role.Level,
+		RoleName:  role.Name,
+		Features:  matrix,
+	}
+
+	return &res, nil
+}
+
+// GetPermissionTemplates lists the built-in templates followed by the stored
+// ones. A built-in name can not be taken by a stored template.
+func (s *roleUsecase) GetPermissionTemplates() ([]entities.PermissionTemplate, error) {
+	stored, err := s.repo.GetAllTemplates()
 	if err != nil {
+		return nil, err
+	}
+
+	templates := append([]entities.PermissionTemplate{}, entities.DefaultPermissionTemplates...)
+	for _, t := range stored {
+		if !isDefaultTemplate(t.Name) {
+			templates = append(templates, t)
+		}
+	}
+
+	return templates, nil
+}
+
+func (s *roleUsecase) CreatePermissionTemplate(template entities.PermissionTemplate) error {
+	decision, err := s.policy.Authorize(template.CreatedBy, entities.ResourcePermissionTemplate, entities.ActionManage)
+	if err != nil {
+		return err
+	}
+	if err := denied(decision, "you do not have permission to manage permission templates"); err != nil {
+		return helpers.Mark(err, helpers.ErrForbidden)
+	}
+
+	if err := helpers.ValidateSlug(template.Name); err != nil {
+		return helpers.Mark(fmt.Errorf("template name may only contain lowercase letters, digits and single hyphens between them"), helpers.ErrInvalid)
+	}
+
+	if isDefaultTemplate(template.Name) {
+		return helpers.Mark(fmt.Errorf("the template name %s is reserved for a built-in template", template.Name), helpers.ErrInvalid)
+	}
+
+	if _, err := s.repo.GetTemplateByName(template.Name); err == nil {
+		return helpers.Mark(fmt.Errorf("the template name alredy exists"), helpers.ErrInvalid)
+	}
+
+	if err := s.repo.CreateTemplate(&template); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+func (s *roleUsecase) ApplyPermissionTemplate(ctx context.Context, roleId uuid.UUID, req entities.ReqApplyTemplate, manageBy uuid.UUID) (*entities.ResRoleDetails, error) {
+	role, _, err := s.repo.GetById(ctx, roleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(manageBy, entities.ActionRoleManage, *role, "the role level you hold must be higher than the role level you are attempting to manage"); err != nil {
+		return nil, err
+	}
+
+	template, err := s.findTemplate(req.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix, err := s.repo.ApplyTemplate(ctx, roleId, *template, req.FeatureIds)
+	if err != nil {
+		return nil, err
+	}
+
+	res := entities.ResRoleDetails{
+		RoleID:    role.ID,
+		RoleLevel: role.Level,
+		RoleName:  role.Name,
+		IsSystem:  role.IsSystem(),
+		Features:  matrix,
+	}
+
+	return &res, nil
+}
+
+// findTemplate looks name up among the built-in templates first, so a stored
+// row can never change what a built-in grants.
+func (s *roleUsecase) findTemplate(name string) (*entities.PermissionTemplate, error) {
+	for _, t := range entities.DefaultPermissionTemplates {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+
+	if template, err := s.repo.GetTemplateByName(name); err == nil {
+		return template, nil
+	}
+
+	return nil, fmt.Errorf("permission template %s not found", name)
+}
+
+func isDefaultTemplate(name string) bool {
+	for _, t := range entities.DefaultPermissionTemplates {
+		if t.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *roleUsecase) ValidateBodyRole(roleName string, roleLevel int32, manageBy uuid.UUID) error {
 
 	if roleName != "" {
@@ -157,15 +423,10 @@ func (s *roleUsecase) ValidateBodyRole(roleName string, roleLevel int32, manageB
 		}
 	}
 
-	userRoleLevel, err := s.repo.GetRoleLevelOfRoleUserByUserId(manageBy)
-	if err != nil {
+	if err := s.authorize(manageBy, entities.ActionRoleCreate, entities.Role{Name: roleName, Level: roleLevel}, "the role level you hold must be higher than the role level you are attempting to create"); err != nil {
 		return err
 	}
 
-	if userRoleLevel.RoleLevel <= roleLevel {
-		return fmt.Errorf("the role level you hold must be higher than the role level you are attempting to create")
-	}
-
 	if roleLevel > 100 || roleLevel < 0 {
 		return fmt.Errorf("the role level can be set from 0 to 100")
 	}
@@ -174,49 +435,80 @@ func (s *roleUsecase) ValidateBodyRole(roleName string, roleLevel int32, manageB
 }
 
 func (s *roleUsecase) ValidateUpdateBodyRole(roleId uuid.UUID, roleLevelCurr int32, roleName string, roleLevel int32, manageBy uuid.UUID) error {
+	violations, err := s.updateViolations(roleId, roleLevelCurr, roleName, roleLevel, manageBy)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return violations[0]
+	}
+
+	return nil
+}
+
+// updateViolations lists every reason an update of the role from
+// roleLevelCurr to roleLevel would be rejected, in the order UpdateRole
+// checks them. Each is marked as a validation or an authorization failure.
+func (s *roleUsecase) updateViolations(roleId uuid.UUID, roleLevelCurr int32, roleName string, roleLevel int32, manageBy uuid.UUID) ([]error, error) {
+	var violations []error
 
 	if roleName != "" {
 		checkName, _ := s.repo.RoleNameIsAlreadyExitsUpdate(roleId, roleName)
 		if checkName {
-			return fmt.Errorf("the role name alredy exists")
+			violations = append(violations, helpers.Mark(fmt.Errorf("the role name alredy exists"), helpers.ErrInvalid))
 		}
 	}
 
-	userRoleLevel, err := s.repo.GetRoleLevelOfRoleUserByUserId(manageBy)
+	decision, err := s.policy.AuthorizeRole(manageBy, entities.ActionRoleManage, entities.Role{ID: roleId, Name: roleName, Level: roleLevelCurr})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if userRoleLevel.RoleLevel <= roleLevel {
-		return fmt.Errorf("the role level you hold must be higher than the role level you are attempting to manage")
+	if err := denied(decision, "the role level you hold must be higher than the role level you are attempting to manage"); err != nil {
+		violations = append(violations, helpers.Mark(err, helpers.ErrForbidden))
 	}
 
-	if userRoleLevel.RoleLevel <= roleLevelCurr {
-		return fmt.Errorf("you role level can not up level this role to more than or equal your level")
+	decision, err = s.policy.AuthorizeRole(manageBy, entities.ActionRoleManage, entities.Role{ID: roleId, Name: roleName, Level: roleLevel})
+	if err != nil {
+		return nil, err
+	}
+	if err := denied(decision, "you role level can not up level this role to more than or equal your level"); err != nil {
+		violations = append(violations, helpers.Mark(err, helpers.ErrForbidden))
 	}
 
 	if roleLevel > 100 || roleLevel < 0 {
-		return fmt.Errorf("the role level can be set from 0 to 100")
+		violations = append(violations, helpers.Mark(fmt.Errorf("the role level can be set from 0 to 100"), helpers.ErrInvalid))
 	}
 
-	return nil
+	return violations, nil
 }
 
-func (s *roleUsecase) ValidateBodyRoleDelete(roleId uuid.UUID, roleLevel int32, manageBy uuid.UUID) error {
+// ValidateBodyRoleDelete reports whether the role still has users, which is
+// only allowed when they are reassigned.
+func (s *roleUsecase) ValidateBodyRoleDelete(roleId uuid.UUID, roleLevel int32, reassignTo *uuid.UUID, manageBy uuid.UUID) (bool, error) {
 
-	userRoleLevel, err := s.repo.GetRoleLevelOfRoleUserByUserId(manageBy)
-	if err != nil {
-		return err
+	if err := s.authorize(manageBy, entities.ActionRoleManage, entities.Role{ID: roleId, Level: roleLevel}, "the role level you hold must be higher than the role level you are attempting to manage"); err != nil {
+		return false, err
 	}
 
-	if userRoleLevel.RoleLevel <= roleLevel {
-		return fmt.Errorf("the role level you hold must be higher than the role level you are attempting to manage")
+	checkHaveUser, err := s.repo.CheckRoleHaveUserUsed(roleId)
+	if err != nil {
+		return false, err
+	}
+	if checkHaveUser && reassignTo == nil {
+		return false, fmt.Errorf("can not delete the role that have user in used")
 	}
 
-	checkHaveUser, _ := s.repo.CheckRoleHaveUserUsed(roleId)
-	if checkHaveUser {
-		return fmt.Errorf("can not delete the role that have user in used")
+	return checkHaveUser, nil
+}
+
+// authorize asks the access policies whether manageBy may perform action on
+// role and returns message when nothing allows it.
+func (s *roleUsecase) authorize(manageBy uuid.UUID, action string, role entities.Role, message string) error {
+	decision, err := s.policy.AuthorizeRole(manageBy, action, role)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return helpers.Mark(denied(decision, message), helpers.ErrForbidden)
 }
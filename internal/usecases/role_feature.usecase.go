@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 	"work01/internal/repositories"
 
 	"github.com/google/uuid"
@@ -12,7 +13,7 @@ type (
 	RoleFeatureUsecase interface {
 		CreateRoleFeature(rolePermission entities.RoleFeature) error
 		GetRoleFeatureById(ctx context.Context, id uuid.UUID) (*entities.RoleFeature, error)
-		GetAllRoleFeatures(ctx context.Context) ([]entities.RoleFeature, error)
+		GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.RoleFeature], error)
 		UpdateRoleFeature(rolePermission entities.RoleFeature) error
 		DeleteRoleFeature(id uuid.UUID) error
 	}
@@ -42,12 +43,12 @@ func (s *rolePermissionUsecase) GetRoleFeatureById(ctx context.Context, id uuid.
 	return rolePermission, nil
 }
 
-func (s *rolePermissionUsecase) GetAllRoleFeatures(ctx context.Context) ([]entities.RoleFeature, error) {
-	rolePermissions, err := s.repo.GetAll(ctx)
+func (s *rolePermissionUsecase) GetAllRoleFeatures(ctx context.Context, pq helpers.PageQuery) (helpers.Pagination[entities.RoleFeature], error) {
+	rolePermissions, total, cursor, err := s.repo.GetAll(ctx, pq)
 	if err != nil {
-		return nil, err
+		return helpers.Pagination[entities.RoleFeature]{}, err
 	}
-	return rolePermissions, nil
+	return helpers.NewPagination(pq, total, cursor, rolePermissions), nil
 }
 
 func (s *rolePermissionUsecase) UpdateRoleFeature(rolePermission entities.RoleFeature) error {
@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type (
+	TranslationUsecase interface {
+		LoadTranslations() error
+		GetLanguages() []string
+		GetTranslations(lang string) ([]entities.Translation, error)
+		SaveTranslation(req entities.ReqTranslation, updBy uuid.UUID) error
+	}
+
+	translationUsecase struct {
+		repo repositories.TranslationRepository
+	}
+)
+
+func NewTranslationUsecase(repo repositories.TranslationRepository) TranslationUsecase {
+	return &translationUsecase{repo: repo}
+}
+
+// LoadTranslations overlays every row of the translations table on the
+// embedded catalogs. It runs at startup; rows saved on another instance are
+// picked up on that instance's next start.
+func (s *translationUsecase) LoadTranslations() error {
+	translations, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range translations {
+		helpers.AddTranslation(t.Lang, t.Namespace, t.Key, t.Value)
+	}
+
+	return nil
+}
+
+func (s *translationUsecase) GetLanguages() []string {
+	return helpers.Languages()
+}
+
+func (s *translationUsecase) GetTranslations(lang string) ([]entities.Translation, error) {
+	translations, err := s.repo.GetByLang(strings.ToLower(lang))
+	if err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+func (s *translationUsecase) SaveTranslation(req entities.ReqTranslation, updBy uuid.UUID) error {
+	lang := strings.ToLower(strings.TrimSpace(req.Lang))
+	if lang == "" || len(lang) > 16 {
+		return fmt.Errorf("lang must be a language tag such as th or en-us")
+	}
+
+	if req.Namespace != helpers.MessageNamespace && req.Namespace != helpers.FeatureNamespace {
+		return fmt.Errorf("namespace must be message or feature")
+	}
+
+	if req.Key == "" || req.Value == "" {
+		return fmt.Errorf("key and value cannot be empty")
+	}
+
+	translation := entities.Translation{
+		Lang:      lang,
+		Namespace: req.Namespace,
+		Key:       req.Key,
+		Value:     req.Value,
+		UpdatedBy: updBy,
+	}
+
+	if err := s.repo.Upsert(&translation); err != nil {
+		return err
+	}
+
+	helpers.AddTranslation(translation.Lang, translation.Namespace, translation.Key, translation.Value)
+
+	return nil
+}
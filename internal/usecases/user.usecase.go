@@ -7,6 +7,7 @@ import (
 	"mime/multipart"
 	"net/mail"
 	"regexp"
+	"time"
 	"unicode"
 
 	"work01/internal/entities"
@@ -20,27 +21,32 @@ import (
 
 type (
 	UserUsecase interface {
-		CreateUser(user entities.ReqUser, fileHeader *multipart.FileHeader) error
+		CreateUser(ctx context.Context, user entities.ReqUser, fileHeader *multipart.FileHeader) error
 		GetUserById(ctx context.Context, id uuid.UUID) (*entities.ResUserDTO, error)
 		GetUserProfileById(id uuid.UUID) (*entities.ResUserProfile, error)
 		GetUserByIdCheckRole(id uuid.UUID) (*entities.User, error)
 		GetAllUsersNoPage() ([]entities.ResUsersNoPage, error)
-		GetAllUsersWithPage(ctx context.Context, page, size int, roleId, isActive string, phoneNumber string, fullName string) (helpers.Pagination, error)
+		GetAllUsersWithPage(ctx context.Context, pq helpers.PageQuery, roleId, isActive string, phoneNumber string, fullName string) (helpers.Pagination[entities.ResAllUserDTOs], error)
 		UpdateUser(ctx context.Context, user entities.ReqUser, fileHeader *multipart.FileHeader) error
 		DeleteUser(ctx context.Context, id uuid.UUID, deleteBy uuid.UUID) error
 		ChangePssword(ctx context.Context, reqPass entities.ReqChangePassword) error
+		GetPermissionOverrides(managerId uuid.UUID, userId uuid.UUID) ([]entities.ResPermissionOverride, error)
+		SavePermissionOverride(managerId uuid.UUID, userId uuid.UUID, req entities.ReqPermissionOverride) ([]entities.ResPermissionOverride, error)
+		DeletePermissionOverride(managerId uuid.UUID, userId uuid.UUID, id uuid.UUID) error
+		ExpireRoleAssignments(ctx context.Context) ([]entities.RoleExpiry, error)
 	}
 
 	userUsecase struct {
-		repo repositories.UserRepository
+		repo   repositories.UserRepository
+		policy PolicyUsecase
 	}
 )
 
-func NewUserUsecase(repo repositories.UserRepository) UserUsecase {
-	return &userUsecase{repo: repo}
+func NewUserUsecase(repo repositories.UserRepository, policy PolicyUsecase) UserUsecase {
+	return &userUsecase{repo: repo, policy: policy}
 }
 
-func (s *userUsecase) CreateUser(user entities.ReqUser, fileHeader *multipart.FileHeader) error {
+func (s *userUsecase) CreateUser(ctx context.Context, user entities.ReqUser, fileHeader *multipart.FileHeader) error {
 	if err := s.CheckVariableToCreate(user.FirstName, user.LastName, user.Email, user.PhoneNumber, user.Password, user.ConfirmPassword); err != nil {
 		return err
 	}
@@ -51,27 +57,25 @@ func (s *userUsecase) CreateUser(user entities.ReqUser, fileHeader *multipart.Fi
 	}
 	user.Password = string(hashedPassword)
 
-	if user.RoleId != nil {
-		var userSelect *entities.User
-		var roleSelect *entities.Role
-
-		userSelect, err := s.repo.GetRoleUserById(user.CreatedBy)
-		if err != nil {
-			return err
-		}
+	if err := s.checkOrgUnit(user.OrgUnitId); err != nil {
+		return err
+	}
 
-		roleSelect, err = s.repo.GetRoleByRoleId(*user.RoleId)
+	assignments := requestedRoles(user)
+	if assignments != nil {
+		roles, err := s.validateRoleAssignment(user.CreatedBy, assignments, user.FallbackRoleId, "create")
 		if err != nil {
 			return err
 		}
+		user.RoleId = primaryRoleId(user.RoleId, roles)
 
-		if userSelect.Role.Level < roleSelect.Level {
-			return fmt.Errorf("your role level (%d) must be higher than the role level (%d) you are attempting to create for user", userSelect.Role.Level, roleSelect.Level)
+		if primaryAssignment(user.RoleId, assignments).ValidUntil != nil && user.FallbackRoleId == nil {
+			return fmt.Errorf("fallbackRoleId is required when the primary role has validUntil")
 		}
 	}
 
 	if fileHeader != nil {
-		avatarURL, err := minio.UploadAvatar(fileHeader)
+		avatarURL, err := minio.UploadAvatar(ctx, fileHeader)
 		if err != nil {
 			return err
 		}
@@ -91,6 +95,7 @@ func (s *userUsecase) CreateUser(user entities.ReqUser, fileHeader *multipart.Fi
 		TwoFactorToken:     user.TwoFactorToken,
 		TwoFactorAuthUrl:   user.TwoFactorAuthUrl,
 		RoleId:             user.RoleId,
+		OrgUnitId:          user.OrgUnitId,
 		ForgotPasswordCode: user.ForgotPasswordCode,
 		IsActive:           user.IsActive,
 		CreatedAt:          user.CreatedAt,
@@ -101,11 +106,12 @@ func (s *userUsecase) CreateUser(user entities.ReqUser, fileHeader *multipart.Fi
 		DeletedBy:          user.DeletedBy,
 	}
 
-	if err := s.repo.Create(userStruct); err != nil {
-		return err
+	if len(assignments) > 1 || user.FallbackRoleId != nil || hasWindow(assignments) {
+		primary := primaryAssignment(user.RoleId, assignments)
+		return s.repo.CreateWithRoles(ctx, userStruct, primary, user.FallbackRoleId, assignments)
 	}
 
-	return nil
+	return s.repo.Create(userStruct)
 }
 
 func (s *userUsecase) GetUserByIdCheckRole(id uuid.UUID) (*entities.User, error) {
@@ -153,13 +159,13 @@ func (s *userUsecase) GetUserById(ctx context.Context, id uuid.UUID) (*entities.
 	return user, nil
 }
 
-func (s *userUsecase) GetAllUsersWithPage(ctx context.Context, page, size int, roleId, isActive string, phoneNumber string, fullName string) (helpers.Pagination, error) {
-	users, total, err := s.repo.GetAllWithPage(ctx, page, size, roleId, isActive, phoneNumber, fullName)
+func (s *userUsecase) GetAllUsersWithPage(ctx context.Context, pq helpers.PageQuery, roleId, isActive string, phoneNumber string, fullName string) (helpers.Pagination[entities.ResAllUserDTOs], error) {
+	users, total, cursor, err := s.repo.GetAllWithPage(ctx, pq, roleId, isActive, phoneNumber, fullName)
 	if err != nil {
-		return helpers.Pagination{}, err
+		return helpers.Pagination[entities.ResAllUserDTOs]{}, err
 	}
 
-	return helpers.Pagiante(page, size, total, users), nil
+	return helpers.NewPagination(pq, total, cursor, users), nil
 }
 
 func (s *userUsecase) GetAllUsersNoPage() ([]entities.ResUsersNoPage, error) {
@@ -185,41 +191,24 @@ func (s *userUsecase) UpdateUser(ctx context.Context, user entities.ReqUser, fil
 		user.Password = string(hashedPassword)
 	}
 
-	if user.ID != user.UpdatedBy {
-		var userUpdater *entities.User
-		var userUpdated *entities.User
-
-		userUpdater, err := s.repo.GetRoleUserById(user.UpdatedBy)
-		if err != nil {
-			return err
-		}
-
-		userUpdated, err = s.repo.GetRoleUserById(user.ID)
-		if err != nil {
-			return err
-		}
-
-		if userUpdater.Role.Level < userUpdated.Role.Level {
-			return fmt.Errorf("you do not have permission to update this user")
-		}
+	if err := s.authorize(user.UpdatedBy, entities.ActionUserUpdate, user.ID, "you do not have permission to update this user"); err != nil {
+		return err
 	}
 
-	if user.RoleId != nil {
-		var userSelect *entities.User
-		var roleSelect *entities.Role
-
-		userSelect, err := s.repo.GetRoleUserById(user.UpdatedBy)
-		if err != nil {
-			return err
-		}
+	if err := s.checkOrgUnit(user.OrgUnitId); err != nil {
+		return err
+	}
 
-		roleSelect, err = s.repo.GetRoleByRoleId(*user.RoleId)
+	assignments := requestedRoles(user)
+	if assignments != nil {
+		roles, err := s.validateRoleAssignment(user.UpdatedBy, assignments, user.FallbackRoleId, "update")
 		if err != nil {
 			return err
 		}
+		user.RoleId = primaryRoleId(user.RoleId, roles)
 
-		if userSelect.Role.Level < roleSelect.Level {
-			return fmt.Errorf("your role level (%d) must be higher than the role level (%d) you are attempting to update for user", userSelect.Role.Level, roleSelect.Level)
+		if primaryAssignment(user.RoleId, assignments).ValidUntil != nil && user.FallbackRoleId == nil {
+			return fmt.Errorf("fallbackRoleId is required when the primary role has validUntil")
 		}
 	}
 
@@ -229,7 +218,7 @@ func (s *userUsecase) UpdateUser(ctx context.Context, user entities.ReqUser, fil
 	}
 
 	if fileHeader != nil {
-		avatarURL, err := minio.UploadAvatarUpdate(fileHeader, avatar.Avatar)
+		avatarURL, err := minio.UploadAvatarUpdate(ctx, fileHeader, avatar.Avatar)
 		if err != nil {
 			return err
 		}
@@ -249,6 +238,7 @@ func (s *userUsecase) UpdateUser(ctx context.Context, user entities.ReqUser, fil
 		TwoFactorToken:     user.TwoFactorToken,
 		TwoFactorAuthUrl:   user.TwoFactorAuthUrl,
 		RoleId:             user.RoleId,
+		OrgUnitId:          user.OrgUnitId,
 		ForgotPasswordCode: user.ForgotPasswordCode,
 		IsActive:           user.IsActive,
 		CreatedAt:          user.CreatedAt,
@@ -259,13 +249,274 @@ func (s *userUsecase) UpdateUser(ctx context.Context, user entities.ReqUser, fil
 		DeletedBy:          user.DeletedBy,
 	}
 
-	if err := s.repo.Update(ctx, &userStruct); err != nil {
-		return err
+	// both writes refuse, under a lock, to leave no active super
+	// administrator
+	if assignments != nil {
+		primary := primaryAssignment(user.RoleId, assignments)
+		return s.repo.UpdateWithRoles(ctx, &userStruct, primary, user.FallbackRoleId, assignments)
+	}
+
+	return s.repo.Update(ctx, &userStruct)
+}
+
+// requestedRoles returns the roles asked for in user: the single roleId with
+// the primary window, roleIds held without an end, and roleAssignments with
+// their own windows. It is nil when none of them is set.
+func requestedRoles(user entities.ReqUser) []entities.RoleAssignment {
+	if user.RoleIds == nil && user.RoleId == nil && user.RoleAssignments == nil {
+		return nil
+	}
+
+	var requested []entities.RoleAssignment
+	if user.RoleId != nil {
+		requested = append(requested, entities.RoleAssignment{RoleId: *user.RoleId, ValidFrom: user.RoleValidFrom, ValidUntil: user.RoleValidUntil})
+	}
+	for _, id := range user.RoleIds {
+		requested = append(requested, entities.RoleAssignment{RoleId: id})
+	}
+	requested = append(requested, user.RoleAssignments...)
+
+	assignments := []entities.RoleAssignment{}
+	seen := map[uuid.UUID]bool{}
+	for _, a := range requested {
+		if !seen[a.RoleId] {
+			seen[a.RoleId] = true
+			assignments = append(assignments, a)
+		}
+	}
+
+	return assignments
+}
+
+func hasWindow(assignments []entities.RoleAssignment) bool {
+	for _, a := range assignments {
+		if a.ValidFrom != nil || a.ValidUntil != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateRoleAssignment checks that every role, the fallback included,
+// exists and may be assigned by managerId under the access policies, and that
+// every window is usable. It returns the assigned roles highest level first.
+func (s *userUsecase) validateRoleAssignment(managerId uuid.UUID, assignments []entities.RoleAssignment, fallbackId *uuid.UUID, action string) ([]entities.Role, error) {
+	if len(assignments) == 0 {
+		if fallbackId != nil {
+			return nil, fmt.Errorf("fallbackRoleId can only be set together with a role")
+		}
+		return nil, nil
+	}
+
+	roleIds := make([]uuid.UUID, 0, len(assignments)+1)
+	for _, a := range assignments {
+		if err := validateWindow(a); err != nil {
+			return nil, err
+		}
+		roleIds = append(roleIds, a.RoleId)
+	}
+
+	checkIds := roleIds
+	if fallbackId != nil && !containsId(roleIds, *fallbackId) {
+		checkIds = append(append([]uuid.UUID{}, roleIds...), *fallbackId)
+	}
+
+	roles, err := s.repo.GetRolesByIds(checkIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) != len(checkIds) {
+		return nil, fmt.Errorf("role not found")
+	}
+
+	manager, err := s.repo.GetRoleUserById(managerId)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make([]entities.Role, 0, len(roleIds))
+	for _, role := range roles {
+		decision, err := s.policy.AuthorizeRole(managerId, entities.ActionRoleAssign, role)
+		if err != nil {
+			return nil, err
+		}
+
+		if !decision.Allowed {
+			if decision.Message != "" {
+				return nil, fmt.Errorf("%s", decision.Message)
+			}
+			if action == "create" {
+				return nil, fmt.Errorf("your role level (%d) must be higher than the role level (%d) you are attempting to create for user", manager.RoleLevel(), role.Level)
+			}
+			return nil, fmt.Errorf("your role level (%d) must be higher than the role level (%d) you are attempting to update for user", manager.RoleLevel(), role.Level)
+		}
+
+		if containsId(roleIds, role.ID) {
+			assigned = append(assigned, role)
+		}
+	}
+
+	return assigned, nil
+}
+
+func validateWindow(a entities.RoleAssignment) error {
+	if a.ValidUntil == nil {
+		return nil
+	}
+
+	if a.ValidFrom != nil && !a.ValidUntil.After(*a.ValidFrom) {
+		return fmt.Errorf("validUntil must be later than validFrom")
+	}
+
+	if !a.ValidUntil.After(time.Now()) {
+		return fmt.Errorf("validUntil must be in the future")
 	}
 
 	return nil
 }
 
+func containsId(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// primaryRoleId keeps the requested primary role, defaulting to the highest
+// level one.
+func primaryRoleId(requested *uuid.UUID, roles []entities.Role) *uuid.UUID {
+	if requested != nil || len(roles) == 0 {
+		return requested
+	}
+
+	return &roles[0].ID
+}
+
+// primaryAssignment returns the assignment of the primary role with its
+// window, or an empty one when the user has no role.
+func primaryAssignment(primaryId *uuid.UUID, assignments []entities.RoleAssignment) entities.RoleAssignment {
+	if primaryId == nil {
+		return entities.RoleAssignment{}
+	}
+
+	for _, a := range assignments {
+		if a.RoleId == *primaryId {
+			return a
+		}
+	}
+
+	return entities.RoleAssignment{RoleId: *primaryId}
+}
+
+// ExpireRoleAssignments ends the role assignments whose window has closed and
+// signs the affected users out so their tokens stop carrying the old role.
+func (s *userUsecase) ExpireRoleAssignments(ctx context.Context) ([]entities.RoleExpiry, error) {
+	expiries, err := s.repo.ExpireRoleAssignments(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := map[uuid.UUID]bool{}
+	for _, expiry := range expiries {
+		if revoked[expiry.UserId] {
+			continue
+		}
+		revoked[expiry.UserId] = true
+
+		if err := s.repo.RevokeSessions(ctx, expiry.UserId, nil); err != nil {
+			return expiries, err
+		}
+	}
+
+	return expiries, nil
+}
+
+func (s *userUsecase) GetPermissionOverrides(managerId uuid.UUID, userId uuid.UUID) ([]entities.ResPermissionOverride, error) {
+	if managerId != userId {
+		if err := s.canOverridePermissions(managerId, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.GetPermissionOverrides(userId)
+}
+
+func (s *userUsecase) SavePermissionOverride(managerId uuid.UUID, userId uuid.UUID, req entities.ReqPermissionOverride) ([]entities.ResPermissionOverride, error) {
+	if err := s.canOverridePermissions(managerId, userId); err != nil {
+		return nil, err
+	}
+
+	if req.Effect != entities.OverrideGrant && req.Effect != entities.OverrideDeny {
+		return nil, fmt.Errorf("effect must be grant or deny")
+	}
+
+	if err := helpers.ValidateAction(req.Action); err != nil {
+		return nil, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expiresAt must be in the future")
+	}
+
+	override := entities.PermissionOverride{
+		ID:        uuid.New(),
+		UserId:    userId,
+		FeatureId: req.FeatureId,
+		Action:    req.Action,
+		Effect:    req.Effect,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: managerId,
+		UpdatedBy: managerId,
+	}
+
+	if err := s.repo.SavePermissionOverride(&override); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetPermissionOverrides(userId)
+}
+
+func (s *userUsecase) DeletePermissionOverride(managerId uuid.UUID, userId uuid.UUID, id uuid.UUID) error {
+	if err := s.canOverridePermissions(managerId, userId); err != nil {
+		return err
+	}
+
+	return s.repo.DeletePermissionOverride(userId, id)
+}
+
+// canOverridePermissions allows managing the overrides of a user only to
+// someone else the access policies let manage them.
+func (s *userUsecase) canOverridePermissions(managerId uuid.UUID, userId uuid.UUID) error {
+	return s.authorize(managerId, entities.ActionUserOverride, userId, "you do not have permission to update this user")
+}
+
+// authorize asks the access policies whether actorId may perform action on
+// userId and returns message when nothing allows it.
+func (s *userUsecase) authorize(actorId uuid.UUID, action string, userId uuid.UUID, message string) error {
+	decision, err := s.policy.AuthorizeUser(actorId, action, userId)
+	if err != nil {
+		return err
+	}
+
+	return denied(decision, message)
+}
+
+// checkOrgUnit makes sure a requested org unit exists.
+func (s *userUsecase) checkOrgUnit(orgUnitId *uuid.UUID) error {
+	if orgUnitId == nil {
+		return nil
+	}
+
+	_, err := s.policy.GetOrgUnitById(*orgUnitId)
+	return err
+}
+
 func (s *userUsecase) ChangePssword(ctx context.Context, reqPass entities.ReqChangePassword) error {
 	if err := s.CheckVariableChangePassword(reqPass.NewPassword, reqPass.ConfirmNewPassword); err != nil {
 		return err
@@ -287,23 +538,8 @@ func (s *userUsecase) ChangePssword(ctx context.Context, reqPass entities.ReqCha
 		reqPass.NewPassword = string(hashedPassword)
 	}
 
-	if reqPass.UserId != reqPass.UpdatedBy {
-		var userUpdater *entities.User
-		var userUpdated *entities.User
-
-		userUpdater, err := s.repo.GetRoleUserById(reqPass.UpdatedBy)
-		if err != nil {
-			return err
-		}
-
-		userUpdated, err = s.repo.GetRoleUserById(reqPass.UserId)
-		if err != nil {
-			return err
-		}
-
-		if userUpdater.Role.Level < userUpdated.Role.Level {
-			return fmt.Errorf("you do not have permission to update this user")
-		}
+	if err := s.authorize(reqPass.UpdatedBy, entities.ActionUserChangePassword, reqPass.UserId, "you do not have permission to update this user"); err != nil {
+		return err
 	}
 
 	userStruct := entities.User{
@@ -325,32 +561,9 @@ func (s *userUsecase) DeleteUser(ctx context.Context, id uuid.UUID, deleteBy uui
 		return err
 	}
 
-	userDel, err := s.repo.GetRoleUserById(id)
-	if err != nil {
-		return err
-	}
-
 	if !check {
-		if deleteBy != id {
-			var userDeleter *entities.User
-			var userDeleted *entities.User
-			userDeleter, err := s.repo.GetRoleUserById(deleteBy)
-			if err != nil {
-				return err
-			}
-
-			userDeleted, err = s.repo.GetRoleUserById(id)
-			if err != nil {
-				return err
-			}
-
-			if userDel.IsActive != nil && !*userDel.IsActive {
-				if userDeleter.Role.Level <= userDeleted.Role.Level {
-					return fmt.Errorf("you do not have permission to delete this user")
-				}
-			} else {
-				return fmt.Errorf("can not delete user that is active")
-			}
+		if err := s.authorize(deleteBy, entities.ActionUserDelete, id, "you do not have permission to delete this user"); err != nil {
+			return err
 		}
 
 		auth, isHave, _ := s.repo.CheckThisUserHaveDataInAuth(id)
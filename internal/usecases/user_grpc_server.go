@@ -2,26 +2,37 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"mime/multipart"
 	"work01/internal/entities"
+	"work01/internal/helpers"
 	"work01/internal/proto/usergrpc"
 
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type userGrpcServiceServer struct {
-	userUsecase UserUsecase
+	userUsecase       UserUsecase
+	permissionUsecase PermissionUsecase
 	usergrpc.UnimplementedUserGrpcServiceServer
 }
 
-func NewUserGrpcServiceServer(usecase UserUsecase) usergrpc.UserGrpcServiceServer {
-	return &userGrpcServiceServer{userUsecase: usecase, UnimplementedUserGrpcServiceServer: usergrpc.UnimplementedUserGrpcServiceServer{}}
+func NewUserGrpcServiceServer(usecase UserUsecase, permissionUsecase PermissionUsecase) usergrpc.UserGrpcServiceServer {
+	return &userGrpcServiceServer{userUsecase: usecase, permissionUsecase: permissionUsecase, UnimplementedUserGrpcServiceServer: usergrpc.UnimplementedUserGrpcServiceServer{}}
 }
 
 func (s userGrpcServiceServer) CreateUser(ctx context.Context, req *usergrpc.CreateUserReq) (*usergrpc.CreateUserRes, error) {
+	creBy, err := actorOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var user entities.ReqUser
 
 	user.ID = uuid.New()
+	user.CreatedBy = creBy
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.PhoneNumber = req.PhoneNumber
@@ -34,7 +45,7 @@ func (s userGrpcServiceServer) CreateUser(ctx context.Context, req *usergrpc.Cre
 	// 	avatarfile = file
 	// }
 
-	if err := s.userUsecase.CreateUser(user, avatarfile); err != nil {
+	if err := s.userUsecase.CreateUser(ctx, user, avatarfile); err != nil {
 		return nil, err
 	}
 
@@ -72,6 +83,15 @@ func (s userGrpcServiceServer) GetUserById(ctx context.Context, req *usergrpc.Ge
 		Avatar:      returnNullGrpc(user.Avatar),
 		RoleId:      roleId,
 		RoleName:    user.RoleName,
+		RoleLevel:   user.RoleLevel,
+	}
+
+	for _, role := range user.Roles {
+		res.Roles = append(res.Roles, &usergrpc.UserRole{
+			RoleId:    role.RoleId.String(),
+			RoleName:  role.RoleName,
+			RoleLevel: role.RoleLevel,
+		})
 	}
 
 	return res, nil
@@ -79,7 +99,23 @@ func (s userGrpcServiceServer) GetUserById(ctx context.Context, req *usergrpc.Ge
 
 func (s userGrpcServiceServer) GetAllUser(ctx context.Context, req *usergrpc.GetAllUserReq) (*usergrpc.GetAllUserRes, error) {
 	var userDTOs []*usergrpc.AllUsersDTO
-	users, err := s.userUsecase.GetAllUsersWithPage(ctx, int(req.Page), int(req.Size), req.RoleId, req.IsActive, req.PhoneNumber, req.FullName)
+	page, size := int(req.Page), int(req.Size)
+	if page == 0 {
+		page = 1
+	}
+	if size == 0 {
+		size = helpers.DefaultPageSize
+	}
+
+	pq, err := helpers.NewPageQuery(page, size, req.Sort, req.Order, "", req.Cursor, entities.UserSortFields, "createdAt")
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	users, err := s.userUsecase.GetAllUsersWithPage(ctx, pq, req.RoleId, req.IsActive, req.PhoneNumber, req.FullName)
+	if errors.Is(err, helpers.ErrInvalid) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -102,18 +138,25 @@ func (s userGrpcServiceServer) GetAllUser(ctx context.Context, req *usergrpc.Get
 		TotalPage:   int32(users.TotalPage),
 		TotalItems:  int32(users.Total),
 		Users:       userDTOs,
+		NextCursor:  users.NextCursor,
 	}
 
 	return res, nil
 }
 
 func (s userGrpcServiceServer) UpdateUserById(ctx context.Context, req *usergrpc.UpdateUserByIdReq) (*usergrpc.UpdateUserByIdRes, error) {
+	updBy, err := actorOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var user entities.ReqUser
 	userId, err := uuid.Parse(req.UserId)
 	if err != nil {
 		return nil, err
 	}
 	user.ID = userId
+	user.UpdatedBy = updBy
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.PhoneNumber = req.PhoneNumber
@@ -134,12 +177,17 @@ func (s userGrpcServiceServer) UpdateUserById(ctx context.Context, req *usergrpc
 }
 
 func (s userGrpcServiceServer) DeleteUserById(ctx context.Context, req *usergrpc.DeleteUserByIdReq) (*usergrpc.DeleteUserByIdRes, error) {
+	delBy, err := actorOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	userId, err := uuid.Parse(req.UserId)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.userUsecase.DeleteUser(ctx, userId, userId); err != nil {
+	if err := s.userUsecase.DeleteUser(ctx, userId, delBy); err != nil {
 		return nil, err
 	}
 
@@ -150,6 +198,109 @@ func (s userGrpcServiceServer) DeleteUserById(ctx context.Context, req *usergrpc
 	return res, nil
 }
 
+// GetUserMenu builds the caller's menu, or the menu of req.UserId when the
+// caller may check that user's permissions.
+func (s userGrpcServiceServer) GetUserMenu(ctx context.Context, req *usergrpc.GetUserMenuReq) (*usergrpc.GetUserMenuRes, error) {
+	actorId, err := actorOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userId := actorId
+	if req.UserId != "" {
+		userId, err = uuid.Parse(req.UserId)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	lang := req.Lang
+	if lang == "" {
+		lang = helpers.LanguageFromContext(ctx)
+	}
+
+	menu, err := s.permissionUsecase.GetUserMenuFor(ctx, actorId, userId, lang)
+	if errors.Is(err, helpers.ErrForbidden) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &usergrpc.GetUserMenuRes{
+		Items: toMenuItemsGrpc(menu),
+	}
+
+	return res, nil
+}
+
+// actorOf returns the user whose access token came with the call. Calls that
+// act on behalf of someone are refused without one.
+func actorOf(ctx context.Context) (uuid.UUID, error) {
+	actorId, ok := helpers.ActorFromContext(ctx)
+	if !ok {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "authorization token is required")
+	}
+
+	return actorId, nil
+}
+
+// CheckPermissions lets other services ask what a user may do, on behalf of
+// the caller whose access token is in the authorization metadata. Asking
+// about someone else needs the same access policy as over HTTP.
+func (s userGrpcServiceServer) CheckPermissions(ctx context.Context, req *usergrpc.CheckPermissionsReq) (*usergrpc.CheckPermissionsRes, error) {
+	actorId, err := actorOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userId := actorId
+	if req.UserId != "" {
+		userId, err = uuid.Parse(req.UserId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checks := make([]entities.PermissionCheck, 0, len(req.Checks))
+	for _, check := range req.Checks {
+		checks = append(checks, entities.PermissionCheck{Feature: check.Feature, Action: check.Action})
+	}
+
+	decisions, err := s.permissionUsecase.CheckPermissionsFor(ctx, actorId, userId, checks)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	res := &usergrpc.CheckPermissionsRes{}
+	for _, d := range decisions {
+		res.Decisions = append(res.Decisions, &usergrpc.PermissionDecision{
+			Feature: d.Feature,
+			Action:  d.Action,
+			Allowed: d.Allowed,
+			Reason:  d.Reason,
+		})
+	}
+
+	return res, nil
+}
+
+func toMenuItemsGrpc(menu []entities.MenuItem) []*usergrpc.MenuItem {
+	var items []*usergrpc.MenuItem
+	for _, item := range menu {
+		items = append(items, &usergrpc.MenuItem{
+			FeatureId: item.FeatureId.String(),
+			Name:      item.Name,
+			MenuSlug:  item.MenuSlug,
+			MenuIcon:  returnNullGrpc(item.MenuIcon),
+			MenuSeqNo: item.MenuSeqNo,
+			Children:  toMenuItemsGrpc(item.Children),
+		})
+	}
+
+	return items
+}
+
 func returnNullGrpc(value *string) string {
 	if value == nil {
 		return ""
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"work01/internal/repositories"
 	"work01/internal/servers"
 	"work01/pkg"
 	"work01/pkg/minio"
@@ -8,23 +12,67 @@ import (
 
 func main() {
 
-	dbServer := servers.NewDBServer()
+	dbServer, err := servers.NewDBServer()
+	if err != nil {
+		log.Fatalf("failed to set up database: %v", err)
+	}
+
+	if len(os.Args) > 1 {
+		os.Exit(pkg.RunCommand(dbServer, os.Args[1:]))
+	}
+
+	shutdownTracing, err := pkg.InitTracing(context.Background())
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	redisClient := pkg.NewRedisClient()
-	minio.NewMinioClient()
+	if err := minio.NewMinioClient(); err != nil {
+		log.Fatalf("failed to set up MinIO: %v", err)
+	}
+
+	bus := repositories.NewInvalidationBus(redisClient)
+	defer bus.Close()
 
 	// app := fiber.New()
+	// app.Use(pkg.TracingMiddleware)
+	// app.Use(pkg.MetricsMiddleware)
+	// app.Get("/metrics", pkg.MetricsHandler)
+
+	// healthHandler := handlers.NewHttpHealthHandler(pkg.NewHealthChecker(dbServer, redisClient))
+	// app.Get("/healthz", healthHandler.LivenessHandler)
+	// app.Get("/readyz", healthHandler.ReadinessHandler)
+
+	// api := app.Group("/api/v2", pkg.TokenValidationMiddleware(redisClient))
+	// authService := app.Group("/auth", pkg.TokenValidationMiddleware(redisClient))
+
+	// policyRepo := repositories.NewPolicyRepository(dbServer)
+	// policyUsecase := usecases.NewPolicyUsecase(policyRepo)
 
-	// api := app.Group("/api/v2", pkg.TokenValidationMiddleware)
-	// authService := app.Group("/auth", pkg.TokenValidationMiddleware)
+	// permissionRepo := repositories.NewPermissionRepository(dbServer, bus)
+	// permissionUsecase := usecases.NewPermissionUsecase(permissionRepo, policyUsecase)
 
-	// authRepo := repositories.NewAuthorizationRepository(dbServer, redisClient)
+	// authRepo := repositories.NewAuthorizationRepository(dbServer, bus)
 	// authUsecase := usecases.NewAuthorizationUsecase(authRepo)
 	// authHandler := handlers.NewHttpAuthorizationHandler(authUsecase)
 
 	// api.Get("/auths", authHandler.GetAllAuthorizationsHandler)
 
-	// userRepo := repositories.NewUserRepository(dbServer, redisClient)
-	// userUsecase := usecases.NewUserUsecase(userRepo)
+	// policyHandler := handlers.NewHttpPolicyHandler(policyUsecase)
+
+	// //access policies
+	// api.Get("/access_policies", policyHandler.GetPoliciesHandler)
+	// api.Post("/access_policies", policyHandler.CreatePolicyHandler)
+	// api.Put("/access_policies/:id", policyHandler.UpdatePolicyHandler)
+	// api.Delete("/access_policies/:id", policyHandler.DeletePolicyHandler)
+	// api.Get("/org_units", policyHandler.GetOrgUnitsHandler)
+	// api.Post("/org_units", policyHandler.CreateOrgUnitHandler)
+	// api.Put("/org_units/:id", policyHandler.UpdateOrgUnitHandler)
+	// api.Delete("/org_units/:id", policyHandler.DeleteOrgUnitHandler)
+
+	// userRepo := repositories.NewUserRepository(dbServer, bus)
+	// userUsecase := usecases.NewUserUsecase(userRepo, policyUsecase)
 	// userHandler := handlers.NewHttpUserHandler(userUsecase)
 
 	// //auth-services
@@ -42,9 +90,12 @@ func main() {
 	// api.Put("/users/changepassword/:id", userHandler.ChangePsswordHandler)
 	// api.Put("/users/:id", userHandler.UpdateUserHandler)
 	// api.Delete("/users/:id", userHandler.DeleteUserHandler)
+	// api.Get("/users/:id/permission_overrides", userHandler.GetPermissionOverridesHandler)
+	// api.Put("/users/:id/permission_overrides", userHandler.SavePermissionOverrideHandler)
+	// api.Delete("/users/:id/permission_overrides/:overrideId", userHandler.DeletePermissionOverrideHandler)
 
-	// roleRepo := repositories.NewRoleRepository(dbServer, redisClient)
-	// roleUsecase := usecases.NewRoleUsecase(roleRepo)
+	// roleRepo := repositories.NewRoleRepository(dbServer, bus)
+	// roleUsecase := usecases.NewRoleUsecase(roleRepo, policyUsecase)
 	// roleHandler := handlers.NewHttpRoleHandler(roleUsecase)
 
 	// //roles
@@ -53,37 +104,81 @@ func main() {
 	// api.Get("/roles", roleHandler.GetAllRolesModifyHandler)
 	// api.Get("/roles_dropdown", roleHandler.GetAllRolesDropdownHandler)
 	// api.Post("/roles", roleHandler.CreateRoleHandler)
+	// api.Post("/roles/:id/clone", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionAdd), roleHandler.CloneRoleHandler)
+	// api.Put("/roles/:id/apply-template", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionEdit), roleHandler.ApplyPermissionTemplateHandler)
+	// api.Post("/roles/:id/preview", roleHandler.PreviewUpdateRoleHandler)
 	// api.Put("/roles/:id", roleHandler.UpdateRoleHandler)
 	// api.Delete("/roles/:id", roleHandler.DeleteRoleHandler)
+	// api.Get("/permission_templates", roleHandler.GetPermissionTemplatesHandler)
+	// api.Post("/permission_templates", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionAdd), roleHandler.CreatePermissionTemplateHandler)
 
-	// featureRepo := repositories.NewFeatureRepository(dbServer, redisClient)
+	// featureRepo := repositories.NewFeatureRepository(dbServer, bus)
 	// featureUsecase := usecases.NewFeatureUsecase(featureRepo)
 	// featureHandler := handlers.NewHttpFeatureHandler(featureUsecase)
 
 	// //features
 	// api.Get("/features_dropdown", featureHandler.GetRefFeatureHandler)
 	// api.Get("/features_default", featureHandler.GetAllFeaturesDefaultHandler)
+	// api.Get("/features/slug/:slug", featureHandler.GetFeatureBySlugHandler)
 	// api.Get("/features/:id", featureHandler.GetFeatureByIdHandler)
 	// api.Get("/features", featureHandler.GetAllFeaturePermissionsHandler)
-	// api.Post("/features", featureHandler.CreateFeatureHandler)
-	// api.Put("/features/:id", featureHandler.UpdateFeatureHandler)
-	// api.Delete("/features/:id", featureHandler.DeleteFeatureHandler)
-
-	// roleFeatureRepo := repositories.NewRoleFeatureRepository(dbServer, redisClient)
+	// api.Post("/features", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionAdd), featureHandler.CreateFeatureHandler)
+	// api.Put("/features/reorder", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionEdit), featureHandler.ReorderFeaturesHandler)
+	// api.Get("/features/:id/delete-preview", featureHandler.GetFeatureDeletePreviewHandler)
+	// api.Put("/features/:id/move", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionEdit), featureHandler.MoveFeatureHandler)
+	// api.Put("/features/:id/restore", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionEdit), featureHandler.RestoreFeatureHandler)
+	// api.Delete("/features/:id/purge", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionDelete), featureHandler.PurgeFeatureHandler)
+	// api.Put("/features/:id", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionEdit), featureHandler.UpdateFeatureHandler)
+	// api.Delete("/features/:id", pkg.PermissionMiddleware(permissionUsecase, "features", entities.PermissionDelete), featureHandler.DeleteFeatureHandler)
+
+	// menuHandler := handlers.NewHttpMenuHandler(permissionUsecase)
+
+	// //menu
+	// api.Get("/menu", menuHandler.GetMenuHandler)
+	// api.Get("/menu/:slug/permissions", menuHandler.GetMenuPermissionHandler)
+	// api.Post("/permissions/check", menuHandler.CheckPermissionsHandler)
+
+	// translationRepo := repositories.NewTranslationRepository(dbServer)
+	// translationUsecase := usecases.NewTranslationUsecase(translationRepo)
+	// translationHandler := handlers.NewHttpTranslationHandler(translationUsecase)
+	// if err := translationUsecase.LoadTranslations(); err != nil {
+	// 	log.Printf("failed to load translations: %v", err)
+	// }
+
+	// //translations
+	// app.Get("/languages", translationHandler.GetLanguagesHandler)
+	// api.Get("/translations/:lang", translationHandler.GetTranslationsHandler)
+	// api.Put("/translations", pkg.PermissionMiddleware(permissionUsecase, "translations", entities.PermissionEdit), translationHandler.SaveTranslationHandler)
+
+	// roleFeatureRepo := repositories.NewRoleFeatureRepository(dbServer, bus)
 	// roleFeatureUsecase := usecases.NewRoleFeatureUsecase(roleFeatureRepo)
 	// roleFeatureHandler := handlers.NewHttpRoleFeatureHandler(roleFeatureUsecase)
 
 	// //roleFeatures
-	// api.Get("/role_features/:id", roleFeatureHandler.GetRoleFeatureByIdHandler)
-	// api.Get("/role_features", roleFeatureHandler.GetAllRoleFeaturesHandler)
-	// api.Post("/role_features", roleFeatureHandler.CreateRoleFeatureHandler)
-	// api.Put("/role_features/:id", roleFeatureHandler.UpdateRoleFeatureHandler)
-	// api.Delete("/role_features/:id", roleFeatureHandler.DeleteRoleFeatureHandler)
+	// api.Get("/role_features/:id", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionView), roleFeatureHandler.GetRoleFeatureByIdHandler)
+	// api.Get("/role_features", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionView), roleFeatureHandler.GetAllRoleFeaturesHandler)
+	// api.Post("/role_features", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionEdit), roleFeatureHandler.CreateRoleFeatureHandler)
+	// api.Put("/role_features/:id", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionEdit), roleFeatureHandler.UpdateRoleFeatureHandler)
+	// api.Delete("/role_features/:id", pkg.PermissionMiddleware(permissionUsecase, "roles", entities.PermissionEdit), roleFeatureHandler.DeleteRoleFeatureHandler)
 
-	// app.Listen(":8080")
+	// auditRepo := repositories.NewAuditRepository(dbServer)
+	// auditUsecase := usecases.NewAuditUsecase(auditRepo)
+	// auditHandler := handlers.NewHttpAuditHandler(auditUsecase)
+
+	// //audit
+	// api.Get("/audit_logs", pkg.PermissionMiddleware(permissionUsecase, "audit-logs", entities.PermissionView), auditHandler.GetAuditLogsHandler)
 
-	// dbServer.Migrator().DropTable(&entities.Role{}, &entities.Feature{}, &entities.User{}, &entities.RoleFeature{}, &entities.Authorization{})
-	// dbServer.AutoMigrate(&entities.Role{}, &entities.Feature{}, &entities.User{}, &entities.RoleFeature{}, &entities.Authorization{})
+	// app.Listen(":8080")
 
-	pkg.NewGRPCServer(dbServer, redisClient)
+	// if err := pkg.MigrateOnStart(dbServer); err != nil {
+	// 	log.Fatalf("failed to migrate database: %v", err)
+	// }
+	// if err := repositories.MigrateLegacyPermissions(dbServer); err != nil {
+	// 	log.Printf("failed to migrate legacy permissions: %v", err)
+	// }
+	// if err := repositories.MarkSystemRoles(dbServer); err != nil {
+	// 	log.Printf("failed to mark system roles: %v", err)
+	// }
+
+	pkg.NewGRPCServer(dbServer, redisClient, bus)
 }
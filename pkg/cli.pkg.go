@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"work01/config"
+	"work01/internal/entities"
+	"work01/internal/helpers"
+	"work01/internal/migrations"
+	"work01/internal/repositories"
+	"work01/internal/usecases"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const cliUsage = `usage: <binary> <command> [flags]
+
+commands:
+  migrate up                    apply pending migrations
+  migrate down [-steps n]       revert the last n migrations (default 1)
+  migrate status                list migrations and when they were applied
+  seed                          create missing seed data
+  create-admin                  create the first super administrator
+      -email -first-name -last-name -phone [-password]
+  reset-password -email [-password]
+  unlock -email                 reactivate an account
+  deactivate -email             deactivate an account and sign it out
+  assign-roles -email -roles id,id [-fallback id]
+  revoke-sessions -email        sign a user out everywhere
+  rotate-keys                   write a new token signing key pair
+
+A password that is not given as a flag is read from the first line of stdin.
+Every command prints a JSON document; failures exit with status 1.`
+
+type cliResult struct {
+	Command string      `json:"command"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// RunCommand runs one administrative command against db and writes the
+// outcome as JSON to stdout. It returns the process exit code.
+func RunCommand(db *gorm.DB, args []string) int {
+	command := strings.Join(args[:min(len(args), 2)], " ")
+	if len(args) > 0 && args[0] != "migrate" {
+		command = args[0]
+	}
+
+	// the query log goes to stderr so stdout stays valid JSON
+	db = db.Session(&gorm.Session{Logger: logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{LogLevel: logger.Warn})})
+
+	result, err := runCommand(db, args)
+	out := cliResult{Command: command, Result: result}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		log.Printf("failed to write result: %v", err)
+		return 1
+	}
+
+	if out.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+func runCommand(db *gorm.DB, args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s", cliUsage)
+	}
+
+	if err := config.LoadConfig(); err != nil {
+		log.Printf("failed to read .env, using the environment only: %v", err)
+	}
+
+	// Redis is only connected for the commands that need it, to clear cached
+	// users and block revoked tokens
+	var bus *repositories.InvalidationBus
+	admin := func() usecases.AdminUsecase {
+		bus = repositories.NewInvalidationBus(NewRedisClient())
+		return usecases.NewAdminUsecase(repositories.NewUserRepository(db, bus))
+	}
+	defer func() {
+		if bus != nil {
+			bus.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	email := flags.String("email", "", "email of the user")
+	password := flags.String("password", "", "new password")
+
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s", cliUsage)
+		}
+		return runMigrate(db, args[1], args[2:])
+
+	case "seed":
+		if err := migrations.Seed(db, seedOptions(config.ReadInConfig())); err != nil {
+			return nil, err
+		}
+		return map[string]string{"status": "seed data is up to date"}, nil
+
+	case "rotate-keys":
+		return helpers.RotateSigningKeys()
+
+	case "create-admin":
+		firstName := flags.String("first-name", "", "first name")
+		lastName := flags.String("last-name", "", "last name")
+		phone := flags.String("phone", "", "phone number")
+		if err := flags.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := readPassword(password); err != nil {
+			return nil, err
+		}
+
+		return admin().CreateSuperAdministrator(ctx, entities.ReqUser{
+			FirstName:       *firstName,
+			LastName:        *lastName,
+			Email:           *email,
+			PhoneNumber:     *phone,
+			Password:        *password,
+			ConfirmPassword: *password,
+		})
+
+	case "reset-password":
+		if err := flags.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := readPassword(password); err != nil {
+			return nil, err
+		}
+		return admin().ResetPassword(ctx, *email, *password)
+
+	case "unlock", "deactivate":
+		if err := flags.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		return admin().SetActive(ctx, *email, args[0] == "unlock")
+
+	case "assign-roles":
+		roles := flags.String("roles", "", "comma separated role ids")
+		fallback := flags.String("fallback", "", "fallback role id")
+		if err := flags.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+
+		var roleIds []uuid.UUID
+		for _, s := range strings.Split(*roles, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid role id: %s", s)
+			}
+			roleIds = append(roleIds, id)
+		}
+
+		var fallbackId *uuid.UUID
+		if *fallback != "" {
+			id, err := uuid.Parse(*fallback)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fallback role id: %s", *fallback)
+			}
+			fallbackId = &id
+		}
+
+		return admin().AssignRoles(ctx, *email, roleIds, fallbackId)
+
+	case "revoke-sessions":
+		if err := flags.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		return admin().RevokeSessions(ctx, *email)
+	}
+
+	return nil, fmt.Errorf("unknown command: %s\n\n%s", args[0], cliUsage)
+}
+
+func runMigrate(db *gorm.DB, action string, args []string) (interface{}, error) {
+	switch action {
+	case "up":
+		ran, err := migrations.Up(db)
+		return migrationNames(ran), err
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		reverted, err := migrations.Down(db, *steps)
+		return migrationNames(reverted), err
+
+	case "status":
+		return migrations.Status(db)
+	}
+
+	return nil, fmt.Errorf("unknown migrate command: %s", action)
+}
+
+func migrationNames(ms []migrations.Migration) []string {
+	names := make([]string, 0, len(ms))
+	for _, m := range ms {
+		names = append(names, fmt.Sprintf("%d_%s", m.Version, m.Name))
+	}
+
+	return names
+}
+
+func readPassword(password *string) error {
+	if *password != "" {
+		return nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	*password = strings.TrimRight(line, "\r\n")
+
+	return nil
+}
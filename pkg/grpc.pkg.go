@@ -1,14 +1,26 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net"
+	"strings"
+	"work01/internal/helpers"
 	"work01/internal/proto/usergrpc"
 	"work01/internal/repositories"
 	"work01/internal/usecases"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 )
 
@@ -16,18 +28,51 @@ const (
 	port = ":50051"
 )
 
-func NewGRPCServer(gormDatabase *gorm.DB, redisClient *redis.Client) {
+func NewGRPCServer(gormDatabase *gorm.DB, redisClient *redis.Client, bus *repositories.InvalidationBus) {
 	listen, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(MetricsInterceptor, LanguageInterceptor, AuthInterceptor(redisClient)),
+	)
 
-	userRepo := repositories.NewUserRepository(gormDatabase, redisClient)
-	userUsecase := usecases.NewUserUsecase(userRepo)
+	if err := MigrateOnStart(gormDatabase); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	if err := repositories.MigrateLegacyPermissions(gormDatabase); err != nil {
+		log.Printf("failed to migrate legacy permissions: %v", err)
+	}
+
+	if err := repositories.MarkSystemRoles(gormDatabase); err != nil {
+		log.Printf("failed to mark system roles: %v", err)
+	}
+
+	translationUsecase := usecases.NewTranslationUsecase(repositories.NewTranslationRepository(gormDatabase))
+	if err := translationUsecase.LoadTranslations(); err != nil {
+		log.Printf("failed to load translations: %v", err)
+	}
+
+	policyUsecase := usecases.NewPolicyUsecase(repositories.NewPolicyRepository(gormDatabase))
 
-	usergrpc.RegisterUserGrpcServiceServer(s, usecases.NewUserGrpcServiceServer(userUsecase))
+	userRepo := repositories.NewUserRepository(gormDatabase, bus)
+	userUsecase := usecases.NewUserUsecase(userRepo, policyUsecase)
+
+	permissionRepo := repositories.NewPermissionRepository(gormDatabase, bus)
+	permissionUsecase := usecases.NewPermissionUsecase(permissionRepo, policyUsecase)
+
+	usergrpc.RegisterUserGrpcServiceServer(s, usecases.NewUserGrpcServiceServer(userUsecase, permissionUsecase))
+
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	go WatchHealth(context.Background(), NewHealthChecker(gormDatabase, redisClient), healthServer, usergrpc.UserGrpcService_ServiceDesc.ServiceName)
+
+	go StartRoleExpiryJob(context.Background(), redisClient, userUsecase, roleExpiryInterval)
+
+	go StartMetricsServer()
 
 	log.Printf("Server is listening on port %v", port)
 
@@ -35,3 +80,68 @@ func NewGRPCServer(gormDatabase *gorm.DB, redisClient *redis.Client) {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+// LanguageInterceptor reads the caller's language from the accept-language
+// (or lang) metadata into the context and translates the message of any
+// error the handler returns.
+func LanguageInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	lang := helpers.DefaultLanguage
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("lang"); len(v) > 0 {
+			lang = helpers.ParseLanguage(v[0])
+		} else if v := md.Get("accept-language"); len(v) > 0 {
+			lang = helpers.ParseLanguage(v[0])
+		}
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs("content-language", lang))
+
+	res, err := handler(helpers.WithLanguage(ctx, lang), req)
+	if err != nil {
+		st := status.Convert(err)
+		return res, status.Error(st.Code(), helpers.Translate(lang, st.Message()))
+	}
+
+	return res, nil
+}
+
+// AuthInterceptor reads the caller's access token from the authorization
+// metadata, checked the same way as TokenValidationMiddleware, and records
+// the caller in the context for the methods that act on its behalf. A request
+// without a token goes on without a caller; a bad token is rejected.
+func AuthInterceptor(redisClient *redis.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok || len(md.Get("authorization")) == 0 {
+			return handler(ctx, req)
+		}
+
+		tokenString := strings.TrimPrefix(md.Get("authorization")[0], "Bearer ")
+
+		token, err := helpers.ValidateToken(tokenString)
+		if err != nil || token == nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		blocked, err := redisClient.Get(ctx, fmt.Sprintf("blocked:%s", tokenString)).Result()
+		if err != nil && err != redis.Nil {
+			log.Printf("Error fetching from Redis: %v", err)
+			return nil, status.Error(codes.Unavailable, "server error")
+		}
+		if blocked == tokenString {
+			return nil, status.Error(codes.Unauthenticated, "token blocked")
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "invalid claims")
+		}
+
+		userId, err := uuid.Parse(fmt.Sprint(claims["userId"]))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "userId missing from token")
+		}
+
+		return handler(helpers.WithActor(ctx, userId), req)
+	}
+}
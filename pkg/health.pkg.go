@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"work01/pkg/minio"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"gorm.io/gorm"
+)
+
+const (
+	HealthOk          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+
+	DependencyUp   = "up"
+	DependencyDown = "down"
+
+	healthCheckTimeout  = 2 * time.Second
+	healthWatchInterval = 10 * time.Second
+)
+
+type (
+	// HealthChecker reports whether the service can take traffic. Postgres and
+	// Redis are required: every authenticated request checks the token
+	// blocklist in Redis and fails without it. MinIO only degrades the service
+	// when it is down.
+	HealthChecker interface {
+		Readiness(ctx context.Context) HealthReport
+	}
+
+	DependencyHealth struct {
+		Name      string  `json:"name"`
+		Status    string  `json:"status"`
+		Required  bool    `json:"required"`
+		LatencyMs float64 `json:"latencyMs"`
+		Error     string  `json:"error,omitempty"`
+	}
+
+	HealthReport struct {
+		Status       string             `json:"status"`
+		Dependencies []DependencyHealth `json:"dependencies"`
+		CheckedAt    time.Time          `json:"checkedAt"`
+	}
+
+	dependencyCheck struct {
+		name     string
+		required bool
+		ping     func(ctx context.Context) error
+	}
+
+	healthChecker struct {
+		checks []dependencyCheck
+	}
+)
+
+func NewHealthChecker(db *gorm.DB, redisClient *redis.Client) HealthChecker {
+	return &healthChecker{checks: []dependencyCheck{
+		{name: "postgres", required: true, ping: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		{name: "redis", required: true, ping: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}},
+		{name: "minio", ping: minio.Ping},
+	}}
+}
+
+// Readiness pings every dependency at once, each with its own timeout.
+func (h *healthChecker) Readiness(ctx context.Context) HealthReport {
+	report := HealthReport{
+		Status:       HealthOk,
+		Dependencies: make([]DependencyHealth, len(h.checks)),
+		CheckedAt:    time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check dependencyCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.ping(ctx)
+
+			dep := DependencyHealth{
+				Name:      check.name,
+				Status:    DependencyUp,
+				Required:  check.required,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				dep.Status = DependencyDown
+				dep.Error = err.Error()
+			}
+			report.Dependencies[i] = dep
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, dep := range report.Dependencies {
+		if dep.Status == DependencyUp {
+			continue
+		}
+		if dep.Required {
+			report.Status = HealthUnavailable
+			break
+		}
+		report.Status = HealthDegraded
+	}
+
+	return report
+}
+
+// WatchHealth keeps the gRPC health service in step with the readiness of
+// the dependencies until ctx is done. A degraded service still serves.
+func WatchHealth(ctx context.Context, checker HealthChecker, server *health.Server, services ...string) {
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		report := checker.Readiness(ctx)
+
+		status := grpc_health_v1.HealthCheckResponse_SERVING
+		if report.Status == HealthUnavailable {
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+		for _, service := range append([]string{""}, services...) {
+			server.SetServingStatus(service, status)
+		}
+
+		if report.Status != last {
+			log.Printf("health: %s", report.Status)
+			last = report.Status
+		}
+
+		select {
+		case <-ctx.Done():
+			server.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
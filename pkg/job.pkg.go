@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"context"
+	"log"
+	"time"
+	"work01/internal/usecases"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	roleExpiryInterval = time.Minute
+	roleExpiryLockKey  = "job:role-expiry"
+)
+
+// StartRoleExpiryJob ends expired role assignments every interval until ctx
+// is done. A Redis lock held for the interval keeps several instances from
+// running the same pass.
+func StartRoleExpiryJob(ctx context.Context, redisClient *redis.Client, userUsecase usecases.UserUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runRoleExpiry(ctx, redisClient, userUsecase, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runRoleExpiry(ctx context.Context, redisClient *redis.Client, userUsecase usecases.UserUsecase, interval time.Duration) {
+	acquired, err := redisClient.SetNX(ctx, roleExpiryLockKey, time.Now().String(), interval).Result()
+	if err != nil {
+		log.Printf("role expiry: failed to take lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	expiries, err := userUsecase.ExpireRoleAssignments(ctx)
+	if err != nil {
+		log.Printf("role expiry: %v", err)
+	}
+
+	if len(expiries) > 0 {
+		log.Printf("role expiry: ended %d role assignment(s)", len(expiries))
+	}
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	"work01/internal/helpers"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const metricsPort = ":9090"
+
+// MetricsHandler serves the Prometheus metrics on the Fiber app.
+var MetricsHandler = adaptor.HTTPHandler(promhttp.Handler())
+
+// MetricsMiddleware records the latency and status of every HTTP request
+// under its route pattern, so /users/:id is one series and not one per user.
+func MetricsMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else {
+			status = fiber.StatusInternalServerError
+		}
+	}
+
+	helpers.ObserveHTTPRequest(c.Method(), c.Route().Path, status, time.Since(start))
+
+	return err
+}
+
+// MetricsInterceptor records the latency and status code of every unary RPC.
+func MetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+
+	helpers.ObserveGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+
+	return res, err
+}
+
+// StartMetricsServer serves /metrics on its own port for the gRPC process,
+// which has no HTTP listener of its own.
+func StartMetricsServer() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Printf("Metrics are served on port %v", metricsPort)
+	if err := http.ListenAndServe(metricsPort, mux); err != nil {
+		log.Printf("metrics server stopped: %v", err)
+	}
+}
@@ -5,65 +5,92 @@ import (
 	"fmt"
 	"log"
 	"work01/internal/helpers"
+	"work01/internal/usecases"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
-func TokenValidationMiddleware(c *fiber.Ctx) error {
-	redisClient := NewRedisClient()
+// TokenValidationMiddleware checks the bearer token of every request against
+// the blocklist kept in redisClient, which is shared with the rest of the app.
+func TokenValidationMiddleware(redisClient *redis.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := c.Get("Authorization")
+		if tokenString == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authorization token is required",
+			})
+		}
 
-	if redisClient == nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Redis client not initialized",
-		})
-	}
+		if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
+			tokenString = tokenString[7:]
+		}
 
-	tokenString := c.Get("Authorization")
-	if tokenString == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authorization token is required",
-		})
-	}
+		token, err := helpers.ValidateToken(tokenString)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 
-	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-		tokenString = tokenString[7:]
-	}
+		cacheKey := fmt.Sprintf("blocked:%s", tokenString)
+		blocked, err := redisClient.Get(context.Background(), cacheKey).Result()
+		// a revoked token can not be told apart without the blocklist, so Redis
+		// is required and the request is refused while it is down
+		if err != nil && err != redis.Nil {
+			log.Printf("Error fetching from Redis: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "server error"})
+		}
 
-	token, err := helpers.ValidateToken(tokenString)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
+		if blocked == tokenString {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token blocked"})
+		}
 
-	cacheKey := fmt.Sprintf("blocked:%s", tokenString)
-	blocked, err := redisClient.Get(context.Background(), cacheKey).Result()
-	if err != nil && err != redis.Nil {
-		log.Printf("Error fetching from Redis: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server error"})
-	}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid claims",
+			})
+		}
 
-	if blocked == tokenString {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "token blocked"})
-	}
+		userId, ok := claims["userId"]
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "userId missing from token",
+			})
+		}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid claims",
-		})
-	}
+		c.Locals("userId", userId)
 
-	userId, ok := claims["userId"]
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "userId missing from token",
-		})
+		return c.Next()
 	}
+}
+
+// PermissionMiddleware must run after TokenValidationMiddleware and rejects
+// callers whose role does not grant action on the feature with slug.
+func PermissionMiddleware(permissionUsecase usecases.PermissionUsecase, slug string, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId, err := uuid.Parse(fmt.Sprint(c.Locals("userId")))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid userId in token",
+			})
+		}
 
-	c.Locals("userId", userId)
+		allowed, err := permissionUsecase.HasPermission(c.UserContext(), userId, slug, action)
+		if err != nil {
+			log.Printf("Error resolving permissions: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "server error"})
+		}
 
-	return c.Next()
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "permission denied",
+			})
+		}
+
+		return c.Next()
+	}
 }
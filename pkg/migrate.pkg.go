@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"log"
+	"work01/config"
+	"work01/internal/migrations"
+
+	"gorm.io/gorm"
+)
+
+func seedOptions(cfg config.Config) migrations.SeedOptions {
+	return migrations.SeedOptions{
+		AdminEmail:    cfg.ADMIN_EMAIL,
+		AdminPassword: cfg.ADMIN_PASSWORD,
+		AdminPhone:    cfg.ADMIN_PHONE,
+	}
+}
+
+// MigrateOnStart applies pending migrations and the seed data unless
+// AUTO_MIGRATE is turned off.
+func MigrateOnStart(db *gorm.DB) error {
+	if err := config.LoadConfig(); err != nil {
+		log.Printf("failed to read .env, using the environment only: %v", err)
+	}
+
+	cfg := config.ReadInConfig()
+	if !cfg.AUTO_MIGRATE {
+		return nil
+	}
+
+	ran, err := migrations.Up(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range ran {
+		log.Printf("applied migration %d_%s", m.Version, m.Name)
+	}
+
+	return migrations.Seed(db, seedOptions(cfg))
+}
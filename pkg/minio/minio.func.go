@@ -9,12 +9,48 @@ import (
 	"path"
 	"strings"
 	"time"
+	"work01/internal/helpers"
 
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func UploadAvatar(fileHeader *multipart.FileHeader) (string, error) {
+const bucketName = "testlocal"
+
+func startSpan(ctx context.Context, operation string, objectName string) (context.Context, trace.Span) {
+	return helpers.StartSpan(ctx, "minio."+operation, trace.SpanKindClient,
+		attribute.String("minio.bucket", bucketName),
+		attribute.String("minio.object", objectName),
+	)
+}
+
+func putObject(ctx context.Context, objectName string, buffer *bytes.Buffer, contentType string) (err error) {
+	size := int64(buffer.Len())
+	ctx, span := startSpan(ctx, "PutObject", objectName)
+	span.SetAttributes(attribute.Int64("minio.size", size))
+	defer func() { helpers.EndSpan(span, err) }()
+
+	_, err = MinioClient.PutObject(ctx, bucketName, objectName, buffer, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return err
+	}
+	helpers.ObserveUpload("avatar", size)
+
+	return nil
+}
+
+func removeObject(ctx context.Context, objectName string) (err error) {
+	ctx, span := startSpan(ctx, "RemoveObject", objectName)
+	defer func() { helpers.EndSpan(span, err) }()
+
+	return MinioClient.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+}
+
+func UploadAvatar(ctx context.Context, fileHeader *multipart.FileHeader) (string, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
 		return "", err
@@ -30,13 +66,8 @@ func UploadAvatar(fileHeader *multipart.FileHeader) (string, error) {
 
 	extension := fileHeader.Filename[strings.LastIndex(fileHeader.Filename, "."):]
 	ojbName := fmt.Sprintf("%s%d%s", strings.Replace(uuid.New().String(), "-", "", -1), time.Now().UnixNano(), extension)
-	bucketName := "testlocal"
 
-	ctx := context.Background()
-	_, err = MinioClient.PutObject(ctx, bucketName, ojbName, buffer, int64(buffer.Len()), minio.PutObjectOptions{
-		ContentType: fileHeader.Header.Get("Content-Type"),
-	})
-	if err != nil {
+	if err := putObject(ctx, ojbName, buffer, fileHeader.Header.Get("Content-Type")); err != nil {
 		return "", err
 	}
 
@@ -44,7 +75,7 @@ func UploadAvatar(fileHeader *multipart.FileHeader) (string, error) {
 	return fileURL, nil
 }
 
-func UploadAvatarUpdate(fileHeader *multipart.FileHeader, avatartURL string) (string, error) {
+func UploadAvatarUpdate(ctx context.Context, fileHeader *multipart.FileHeader, avatartURL string) (string, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
 		return "", err
@@ -58,15 +89,11 @@ func UploadAvatarUpdate(fileHeader *multipart.FileHeader, avatartURL string) (st
 		return "", err
 	}
 
-	bucketName := "testlocal"
-	ctx := context.Background()
-
 	if avatartURL != "" {
 		Fullpath := avatartURL
 		oldOjbName := path.Base(Fullpath)
 
-		err = MinioClient.RemoveObject(ctx, bucketName, oldOjbName, minio.RemoveObjectOptions{})
-		if err != nil {
+		if err := removeObject(ctx, oldOjbName); err != nil {
 			log.Printf("error removing old file: %v", err)
 		} else {
 			fmt.Printf("Old file %s deleted success", oldOjbName)
@@ -76,13 +103,37 @@ func UploadAvatarUpdate(fileHeader *multipart.FileHeader, avatartURL string) (st
 	extension := fileHeader.Filename[strings.LastIndex(fileHeader.Filename, "."):]
 	ojbName := fmt.Sprintf("%s%d%s", strings.Replace(uuid.New().String(), "-", "", -1), time.Now().UnixNano(), extension)
 
-	_, err = MinioClient.PutObject(ctx, bucketName, ojbName, buffer, int64(buffer.Len()), minio.PutObjectOptions{
-		ContentType: fileHeader.Header.Get("Content-Type"),
-	})
-	if err != nil {
+	if err := putObject(ctx, ojbName, buffer, fileHeader.Header.Get("Content-Type")); err != nil {
 		return "", err
 	}
 
 	fileURL := fmt.Sprintf("%s/%s/%s", MinioClient.EndpointURL().String(), bucketName, ojbName)
 	return fileURL, nil
 }
+
+func RemoveFile(ctx context.Context, fileURL string) error {
+	ojbName := path.Base(fileURL)
+
+	if err := removeObject(ctx, ojbName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Ping checks that MinIO answers and the avatar bucket is there.
+func Ping(ctx context.Context) error {
+	if MinioClient == nil {
+		return fmt.Errorf("minio client is not initialized")
+	}
+
+	exists, err := MinioClient.BucketExists(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", bucketName)
+	}
+
+	return nil
+}
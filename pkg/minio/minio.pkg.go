@@ -1,6 +1,7 @@
 package minio
 
 import (
+	"fmt"
 	"log"
 	"work01/config"
 
@@ -10,9 +11,9 @@ import (
 
 var MinioClient *minio.Client
 
-func NewMinioClient() {
+func NewMinioClient() error {
 	if err := config.LoadConfig(); err != nil {
-		log.Fatalf("can not connect to config")
+		log.Printf("failed to read .env, using the environment only: %v", err)
 	}
 
 	cfg := config.ReadInConfig()
@@ -28,8 +29,10 @@ func NewMinioClient() {
 		Secure: useSSL,
 	})
 	if err != nil {
-		log.Fatalf("can not connect to MinIO: %v", err)
+		return fmt.Errorf("can not connect to MinIO: %w", err)
 	}
 
 	log.Println("Connect to MinIO Success")
+
+	return nil
 }
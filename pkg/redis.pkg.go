@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -13,8 +14,14 @@ func NewRedisClient() *redis.Client {
 		Password: "admin",
 	})
 
+	if err := redisotel.InstrumentTracing(redisClient); err != nil {
+		log.Printf("failed to trace Redis: %v", err)
+	}
+
+	// the client reconnects on its own; the service reports itself not ready
+	// until it does
 	if _, err := redisClient.Ping(context.Background()).Result(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Printf("Failed to connect to Redis: %v", err)
 	}
 
 	return redisClient
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"work01/config"
+	"work01/internal/helpers"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TraceExporterOtlp   = "otlp"
+	TraceExporterStdout = "stdout"
+	TraceExporterNone   = "none"
+)
+
+// InitTracing installs the global tracer provider and the W3C trace-context
+// propagator. TRACE_EXPORTER picks where spans go: "otlp" sends them to the
+// collector at OTEL_EXPORTER_OTLP_ENDPOINT, "stdout" prints them and "none"
+// only propagates the incoming trace context. The returned function flushes
+// the spans that are still buffered.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	if err := config.LoadConfig(); err != nil {
+		log.Printf("failed to read .env, using the environment only: %v", err)
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	cfg := config.ReadInConfig()
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TRACE_EXPORTER {
+	case TraceExporterOtlp:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLP_ENDPOINT)}
+		if cfg.OTLP_INSECURE {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case TraceExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	case TraceExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", cfg.TRACE_EXPORTER)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.SERVICE_NAME)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	log.Printf("Traces are exported to %s", cfg.TRACE_EXPORTER)
+
+	return provider.Shutdown, nil
+}
+
+// fiberCarrier reads the trace context from the request headers.
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+func (f fiberCarrier) Get(key string) string {
+	return f.c.Get(key)
+}
+
+func (f fiberCarrier) Set(key string, value string) {
+	f.c.Set(key, value)
+}
+
+func (f fiberCarrier) Keys() []string {
+	keys := make([]string, 0)
+	f.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
+
+// TracingMiddleware continues the caller's trace, or starts one, for every
+// HTTP request. Handlers pass c.UserContext() on so the queries, cache calls
+// and uploads of the request become its children.
+func TracingMiddleware(c *fiber.Ctx) error {
+	carrier := fiberCarrier{c}
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+
+	ctx, span := helpers.StartSpan(ctx, c.Method()+" "+c.Path(), trace.SpanKindServer,
+		semconv.HTTPRequestMethodKey.String(c.Method()),
+		semconv.URLPath(c.Path()),
+		attribute.String("http.client_ip", c.IP()),
+	)
+	defer span.End()
+
+	c.SetUserContext(ctx)
+	err := c.Next()
+
+	// the route is only known once the router has matched it
+	route := c.Route().Path
+	span.SetName(c.Method() + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else {
+			status = fiber.StatusInternalServerError
+		}
+		span.RecordError(err)
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+
+	return err
+}